@@ -30,6 +30,10 @@ DEFAULT_NAMESPACE="podfiles"
 DEFAULT_IMAGE="podfiles:latest"
 DEFAULT_SERVICE_TYPE="ClusterIP"
 NS_BLACK_LIST=""
+IMPERSONATE="false"
+IMPERSONATE_GROUPS=""
+POD_EVENTS="true"
+DEBUG_CONTAINER="false"
 
 # Read user input with default value
 read_input() {
@@ -78,6 +82,37 @@ echo -e "${BLUE}The blacklist supports wildcards '*' at the beginning or end onl
 echo -e "${BLUE}Please enter the list in single quotes, e.g., 'abc,*zz,hh*'${NC}"
 NS_BLACK_LIST=$(read_input "Enter namespace black list" "'kube-*,default'")
 
+echo
+echo -e "${BLUE}PodFiles can impersonate its users, so that their own Kubernetes RBAC applies instead of podFiles' service account.${NC}"
+echo -e "${BLUE}It needs authenticated users and grants podFiles the impersonate verb.${NC}"
+ENABLE_IMPERSONATION=$(read_input "Enable impersonation (y/n)" "n")
+if [[ $ENABLE_IMPERSONATION =~ ^[Yy] ]]; then
+    IMPERSONATE="true"
+    echo -e "${BLUE}Only the listed groups are impersonated, the other groups of the users are dropped.${NC}"
+    echo -e "${BLUE}Local accounts and their groups are impersonated as podfiles:<name>, list their groups with the prefix, e.g. 'qa,podfiles:dev'${NC}"
+    IMPERSONATE_GROUPS=$(read_input "Enter groups to impersonate" "")
+fi
+# the RBAC rule needs a group, system:authenticated is one every user has anyway
+IMPERSONATE_GROUP_NAMES='"system:authenticated"'
+if [ -n "$IMPERSONATE_GROUPS" ]; then
+    IMPERSONATE_GROUP_NAMES=$(echo "$IMPERSONATE_GROUPS" | tr -d " '" | sed 's/[^,][^,]*/"&"/g')
+fi
+
+echo
+echo -e "${BLUE}PodFiles can record the uploads and other file changes as events of the pods, shown by kubectl describe pod.${NC}"
+ENABLE_POD_EVENTS=$(read_input "Enable pod events (y/n)" "y")
+if [[ ! $ENABLE_POD_EVENTS =~ ^[Yy] ]]; then
+    POD_EVENTS="false"
+fi
+
+echo
+echo -e "${BLUE}PodFiles can add an ephemeral debug container to the pods whose images lack a shell, such as distroless.${NC}"
+echo -e "${BLUE}It lets podFiles patch the ephemeral containers of every pod, which run as the pod and see its filesystem.${NC}"
+ENABLE_DEBUG_CONTAINER=$(read_input "Enable debug containers (y/n)" "n")
+if [[ $ENABLE_DEBUG_CONTAINER =~ ^[Yy] ]]; then
+    DEBUG_CONTAINER="true"
+fi
+
 # Display configuration for confirmation
 echo
 echo -e "${GREEN}Configuration Summary:${NC}"
@@ -88,6 +123,12 @@ if [ -n "$INGRESS_DOMAIN" ]; then
     echo -e "  ${BLUE}Ingress Domain:${NC} ${YELLOW}$INGRESS_DOMAIN${NC}"
 fi
 echo -e "${BLUE}Namespace Black List:${NC} ${YELLOW}$NS_BLACK_LIST${NC}"
+echo -e "${BLUE}Impersonation:${NC} ${YELLOW}$IMPERSONATE${NC}"
+if [ "$IMPERSONATE" = "true" ]; then
+    echo -e "  ${BLUE}Impersonated Groups:${NC} ${YELLOW}$IMPERSONATE_GROUPS${NC}"
+fi
+echo -e "${BLUE}Pod Events:${NC} ${YELLOW}$POD_EVENTS${NC}"
+echo -e "${BLUE}Debug Containers:${NC} ${YELLOW}$DEBUG_CONTAINER${NC}"
 
 
 # Ask for confirmation
@@ -119,6 +160,14 @@ rules:
   - apiGroups: [""]
     resources: ["pods/exec"]
     verbs: ["create", "get"]
+  # events on the pods whose files change
+  - apiGroups: [""]
+    resources: ["events"]
+    verbs: ["create"]
+  # login with the users' bearer tokens
+  - apiGroups: ["authentication.k8s.io"]
+    resources: ["tokenreviews"]
+    verbs: ["create"]
 ---
 # RBAC RoleBinding
 apiVersion: rbac.authorization.k8s.io/v1
@@ -136,6 +185,74 @@ roleRef:
 
 EOF
 
+# Apply impersonation resources if enabled
+if [ "$IMPERSONATE" = "true" ]; then
+    echo -e "${BLUE}Applying impersonation resources...${NC}"
+    kubectl apply -f - << EOF
+# RBAC Role to impersonate the podFiles users, so that their own RBAC applies
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: podfiles-impersonator
+rules:
+  - apiGroups: [""]
+    resources: ["users"]
+    verbs: ["impersonate"]
+  # only the groups podFiles passes on, never system:masters or the like;
+  # resourceNames must not be empty, that would allow every group
+  - apiGroups: [""]
+    resources: ["groups"]
+    verbs: ["impersonate"]
+    resourceNames: [${IMPERSONATE_GROUP_NAMES}]
+---
+# RBAC RoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: podfiles-impersonator-binding
+subjects:
+  - kind: ServiceAccount
+    name: podfiles-sa
+    namespace: ${NAMESPACE}
+roleRef:
+  kind: ClusterRole
+  name: podfiles-impersonator
+  apiGroup: rbac.authorization.k8s.io
+
+EOF
+fi
+
+# Apply debug container resources if enabled
+if [ "$DEBUG_CONTAINER" = "true" ]; then
+    echo -e "${BLUE}Applying debug container resources...${NC}"
+    kubectl apply -f - << EOF
+# RBAC Role to add debug containers to pods whose images lack a shell, such as distroless
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: podfiles-debugger
+rules:
+  - apiGroups: [""]
+    resources: ["pods/ephemeralcontainers"]
+    verbs: ["get", "patch", "update"]
+---
+# RBAC RoleBinding
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: podfiles-debugger-binding
+subjects:
+  - kind: ServiceAccount
+    name: podfiles-sa
+    namespace: ${NAMESPACE}
+roleRef:
+  kind: ClusterRole
+  name: podfiles-debugger
+  apiGroup: rbac.authorization.k8s.io
+
+EOF
+fi
+
 # Apply namespace resources
 echo -e "${BLUE}Applying namespace resources...${NC}"
 kubectl apply -n $NAMESPACE -f - << EOF
@@ -181,6 +298,14 @@ spec:
           env:
             - name: NS_BLACK_LIST
               value: "${NS_BLACK_LIST}"
+            - name: IMPERSONATE
+              value: "${IMPERSONATE}"
+            - name: IMPERSONATE_GROUPS
+              value: "${IMPERSONATE_GROUPS}"
+            - name: POD_EVENTS
+              value: "${POD_EVENTS}"
+            - name: DEBUG_CONTAINER
+              value: "${DEBUG_CONTAINER}"
           livenessProbe:
             httpGet:
               path: /health
@@ -208,7 +333,7 @@ spec:
       port: 80
       targetPort: 8080
   type: ${SERVICE_TYPE}
-" | IMAGE=$IMAGE NS_BLACK_LIST="'$NS_BLACK_LIST'" envsubst)
+" | IMAGE=$IMAGE NS_BLACK_LIST="'$NS_BLACK_LIST'" IMPERSONATE=$IMPERSONATE IMPERSONATE_GROUPS=$IMPERSONATE_GROUPS POD_EVENTS=$POD_EVENTS DEBUG_CONTAINER=$DEBUG_CONTAINER envsubst)
 EOF
 
 # Apply ingress if domain is provided
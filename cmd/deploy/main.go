@@ -18,10 +18,18 @@ var namespaceResources string
 //go:embed tpl/ingress.yaml
 var ingress string
 
+//go:embed tpl/impersonation.yaml
+var impersonation string
+
+//go:embed tpl/debug-containers.yaml
+var debugContainers string
+
 type ScriptData struct {
 	ClusterResources   string
 	NamespaceResources string
 	Ingress            string
+	Impersonation      string
+	DebugContainers    string
 }
 
 func main() {
@@ -32,6 +40,8 @@ func main() {
 		ClusterResources:   clusterResources,
 		NamespaceResources: namespaceResources,
 		Ingress:            ingress,
+		Impersonation:      impersonation,
+		DebugContainers:    debugContainers,
 	}
 
 	f, err := os.Create("cmd/deploy/apply.sh")
@@ -1,23 +1,39 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/netip"
 	"os"
 
 	"github.com/zrcoder/podFiles/conf"
 	"github.com/zrcoder/podFiles/internal/api"
+	"github.com/zrcoder/podFiles/internal/audit"
 	"github.com/zrcoder/podFiles/internal/auth"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/backend/local"
+	"github.com/zrcoder/podFiles/internal/k8s"
+	"github.com/zrcoder/podFiles/internal/oidc"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/state"
 	"github.com/zrcoder/podFiles/internal/ui"
+	"github.com/zrcoder/podFiles/internal/user"
 
 	"github.com/zrcoder/amisgo"
 )
 
 func main() {
 	fmt.Println("Starting...")
+	checkProxy()
+	sessions := newSessionStore()
+	state.Use(sessions)
 	app := amisgo.New(conf.Options()...)
-	app.Mount("/", ui.Index(app))
-	app.Mount(ui.FilesPage, ui.FileList(app), auth.K8s)
-	app.Handle(api.Prefix, api.New())
+	app.Mount(auth.LoginPage, ui.Login(app), auth.SafeNext)
+	app.Mount("/", ui.Index(app), auth.Page)
+	app.Mount(auth.FilesPage, ui.FileList(app), auth.K8s)
+	b := newBackend()
+	app.Handle(api.Prefix, api.New(b, api.Authenticators{Users: newUsers(), OIDC: newOIDC(sessions), Tokens: newTokens(b)}, newPolicy(), newAudit()))
 	app.HandleFunc(api.HealthPath, api.Healthz)
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -26,3 +42,148 @@ func main() {
 	fmt.Println("Serving on http://localhost:" + port)
 	app.Run("0.0.0.0:" + port)
 }
+
+func newBackend() backend.Backend {
+	if root := conf.LocalRoot(); root != "" {
+		fmt.Println("Serving local directory " + root)
+		b, err := local.New(root)
+		if err != nil {
+			panic(err)
+		}
+		return b
+	}
+	b, err := k8s.New()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// newUsers returns the local accounts with the admin, or nil if they are disabled.
+func newUsers() *user.Store {
+	if !conf.AuthEnabled(conf.AuthLocal) {
+		return nil
+	}
+	users, err := user.New(conf.UsersFile())
+	if err != nil {
+		panic(err)
+	}
+	password, err := conf.AdminPassword()
+	if err != nil {
+		panic(err)
+	}
+	generated, err := users.Bootstrap(conf.AdminUser(), password)
+	if err != nil {
+		panic(err)
+	}
+	if generated != "" {
+		fmt.Printf("Created user %s with password %s\n", conf.AdminUser(), generated)
+	}
+	return users
+}
+
+// newTokens returns what reviews the bearer tokens of the users, or nil if the token login is disabled.
+func newTokens(b backend.Backend) backend.TokenReviewer {
+	if !conf.AuthEnabled(conf.AuthToken) {
+		return nil
+	}
+	tr, ok := b.(backend.TokenReviewer)
+	if !ok {
+		panic("AUTH=token requires a Kubernetes cluster")
+	}
+	return tr
+}
+
+// newPolicy returns the access policy, or nil to let everyone read and write.
+func newPolicy() *policy.Policy {
+	file := conf.PolicyFile()
+	if file == "" {
+		return nil
+	}
+	p, err := policy.Load(file)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// newAudit returns the audit log, written to the configured sink.
+func newAudit() *audit.Logger {
+	var sink io.Writer
+	switch file := conf.AuditLog(); file {
+	case conf.AuditNone:
+	case conf.AuditStdout:
+		sink = os.Stdout
+	default:
+		maxSize, backups := conf.AuditRotation()
+		f, err := audit.OpenRotatingFile(file, maxSize, backups)
+		if err != nil {
+			panic(err)
+		}
+		sink = f
+	}
+	return audit.New(sink, conf.AuditKeep())
+}
+
+// newSessionStore returns the configured session store.
+func newSessionStore() state.SessionStore {
+	switch store := conf.SessionStore(); store {
+	case conf.SessionMemory:
+		return state.NewMemoryStore()
+	case conf.SessionFile:
+		if conf.SessionDir() == "" {
+			panic("SESSION_STORE=file requires SESSION_DIR")
+		}
+		s, err := state.NewFileStore(conf.SessionDir())
+		if err != nil {
+			panic(err)
+		}
+		return s
+	case conf.SessionRedis:
+		s, err := state.NewRedisStore(conf.SessionRedisURL())
+		if err != nil {
+			panic(err)
+		}
+		return s
+	default:
+		panic("unknown SESSION_STORE " + store)
+	}
+}
+
+// checkProxy fails fast when the proxy auth has no valid trusted proxies, it would reject every request.
+func checkProxy() {
+	if !conf.AuthEnabled(conf.AuthProxy) {
+		return
+	}
+	cidrs := conf.ProxyTrustedCIDRs()
+	if len(cidrs) == 0 {
+		panic("AUTH=proxy requires PROXY_TRUSTED_CIDRS")
+	}
+	for _, c := range cidrs {
+		if _, err := netip.ParsePrefix(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// newOIDC returns the OpenID Connect provider keeping its pending logins in sessions, or nil if it is disabled.
+func newOIDC(sessions state.SessionStore) *oidc.Provider {
+	if !conf.AuthEnabled(conf.AuthOIDC) {
+		return nil
+	}
+	id, secret := conf.OIDCClient()
+	userClaim, groupsClaim := conf.OIDCClaims()
+	p, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:       conf.OIDCIssuer(),
+		ClientID:     id,
+		ClientSecret: secret,
+		Scopes:       conf.OIDCScopes(),
+		UserClaim:    userClaim,
+		GroupsClaim:  groupsClaim,
+		Store:        sessions,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
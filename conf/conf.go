@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +33,81 @@ const (
 	nsBlackListEnv   = "NS_BLACK_LIST"
 	servicePrefixEnv = "SVC_PREFIX"
 	kubeConfigEnv    = "KUBECONFIG"
+	kubeContextsEnv  = "KUBE_CONTEXTS"
+	clusterNameEnv   = "CLUSTER_NAME"
+	localRootEnv     = "LOCAL_ROOT"
+	debugImageEnv    = "DEBUG_IMAGE"
+	debugEnv         = "DEBUG_CONTAINER"
+	impersonateEnv   = "IMPERSONATE"
+	authEnv          = "AUTH"
+	usersFileEnv     = "USERS_FILE"
+	adminUserEnv     = "ADMIN_USER"
+	adminPasswordEnv = "ADMIN_PASSWORD"
+	// adminPasswordFileEnv is preferred to adminPasswordEnv for mounted secrets
+	adminPasswordFileEnv = "ADMIN_PASSWORD_FILE"
+	signUpEnv            = "SIGN_UP"
+	impersonateGroupsEnv = "IMPERSONATE_GROUPS"
+	oidcIssuerEnv        = "OIDC_ISSUER"
+	oidcClientIDEnv      = "OIDC_CLIENT_ID"
+	oidcClientSecretEnv  = "OIDC_CLIENT_SECRET"
+	oidcScopesEnv        = "OIDC_SCOPES"
+	oidcUserClaimEnv     = "OIDC_USER_CLAIM"
+	oidcGroupsClaimEnv   = "OIDC_GROUPS_CLAIM"
+	oidcRedirectURLEnv   = "OIDC_REDIRECT_URL"
+	proxyUserHeaderEnv   = "PROXY_USER_HEADER"
+	proxyGroupsHeaderEnv = "PROXY_GROUPS_HEADER"
+	proxyTrustedEnv      = "PROXY_TRUSTED_CIDRS"
+	trustedProxiesEnv    = "TRUSTED_PROXIES"
+	policyFileEnv        = "POLICY_FILE"
+	auditLogEnv          = "AUDIT_LOG"
+	auditMaxSizeEnv      = "AUDIT_MAX_SIZE"
+	auditBackupsEnv      = "AUDIT_BACKUPS"
+	auditKeepEnv         = "AUDIT_KEEP"
+	podEventsEnv         = "POD_EVENTS"
+	sessionStoreEnv      = "SESSION_STORE"
+	sessionDirEnv        = "SESSION_DIR"
+	sessionRedisURLEnv   = "SESSION_REDIS_URL"
+	viewMaxSizeEnv       = "VIEW_MAX_SIZE"
+
+	defaultDebugImage  = "busybox:1.36"
+	defaultClusterName = "in-cluster"
+	defaultAdminUser   = "admin"
+	defaultOIDCScopes  = "openid,profile,email,groups"
+	defaultUserClaim   = "email"
+	defaultGroupsClaim = "groups"
+	defaultUserHeader  = "X-Forwarded-User"
+	defaultGroupHeader = "X-Forwarded-Groups"
+	defaultAuditMaxMB  = 100
+	defaultAuditBackup = 5
+	defaultAuditKeep   = 1000
+	defaultViewMaxKB   = 1024
+)
+
+// Session stores, see SessionStore
+const (
+	SessionMemory = "memory"
+	SessionFile   = "file"
+	SessionRedis  = "redis"
+)
+
+// Audit sinks besides files, see AuditLog
+const (
+	AuditStdout = "stdout"
+	AuditNone   = "none"
+)
+
+// Authentication providers, see AuthEnabled
+const (
+	// AuthLocal logs in with the local accounts
+	AuthLocal = "local"
+	// AuthOIDC logs in with an OpenID Connect provider
+	AuthOIDC = "oidc"
+	// AuthToken logs in with a Kubernetes bearer token, checked by a TokenReview
+	AuthToken = "token"
+	// AuthProxy trusts the identity headers set by an authenticating reverse proxy
+	AuthProxy = "proxy"
+	// AuthNone lets anyone in with an anonymous session
+	AuthNone = "none"
 )
 
 var (
@@ -88,3 +164,242 @@ func NsInBlacklist(ns string) bool {
 func KubeConfigPath() string {
 	return os.Getenv(kubeConfigEnv)
 }
+
+// KubeContexts returns the kubeconfig contexts to serve as clusters, all of them if empty.
+func KubeContexts() []string {
+	var contexts []string
+	for _, c := range strings.Split(os.Getenv(kubeContextsEnv), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			contexts = append(contexts, c)
+		}
+	}
+	return contexts
+}
+
+// ClusterName returns the name of the cluster podFiles runs in, used without a kubeconfig.
+func ClusterName() string {
+	if name := os.Getenv(clusterNameEnv); name != "" {
+		return name
+	}
+	return defaultClusterName
+}
+
+// LocalRoot returns the local directory to serve instead of a cluster, if any.
+func LocalRoot() string {
+	return os.Getenv(localRootEnv)
+}
+
+// DebugContainerEnabled reports whether an ephemeral debug container may be added
+// to pods whose containers lack the tools podFiles needs, it is off by default.
+func DebugContainerEnabled() bool {
+	return os.Getenv(debugEnv) == "true"
+}
+
+// PodEvents reports whether the file changes are recorded as events of their pods.
+func PodEvents() bool {
+	return os.Getenv(podEventsEnv) != "false"
+}
+
+// Impersonate reports whether the cluster calls impersonate the podFiles users,
+// so that their own RBAC applies instead of podFiles' service account.
+func Impersonate() bool {
+	return os.Getenv(impersonateEnv) == "true"
+}
+
+// ImpersonateGroups returns the groups that may be impersonated, the other groups of the users are dropped.
+// Local accounts and their groups are impersonated with the podfiles: prefix, so their groups are listed with it.
+func ImpersonateGroups() []string {
+	return listEnv(impersonateGroupsEnv)
+}
+
+// DebugImage returns the image of the debug containers, it needs a busybox-style userland.
+func DebugImage() string {
+	if image := os.Getenv(debugImageEnv); image != "" {
+		return image
+	}
+	return defaultDebugImage
+}
+
+// AuthEnabled reports whether the authentication provider is enabled,
+// AUTH lists them separated by commas, local by default.
+func AuthEnabled(provider string) bool {
+	auth := os.Getenv(authEnv)
+	if auth == "" {
+		auth = AuthLocal
+	}
+	for _, p := range strings.Split(auth, ",") {
+		if strings.TrimSpace(p) == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// UsersFile returns the JSON file of the local accounts, they are kept in memory only if empty.
+func UsersFile() string {
+	return os.Getenv(usersFileEnv)
+}
+
+// AdminUser returns the name of the admin account created at startup.
+func AdminUser() string {
+	if name := os.Getenv(adminUserEnv); name != "" {
+		return name
+	}
+	return defaultAdminUser
+}
+
+// AdminPassword returns the password of the admin account, empty to generate one.
+func AdminPassword() (string, error) {
+	if file := os.Getenv(adminPasswordFileEnv); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return os.Getenv(adminPasswordEnv), nil
+}
+
+// SignUpEnabled reports whether anyone may register a local account,
+// otherwise only admins can.
+func SignUpEnabled() bool {
+	return os.Getenv(signUpEnv) == "true"
+}
+
+// OIDCIssuer returns the issuer URL of the OpenID Connect provider.
+func OIDCIssuer() string {
+	return os.Getenv(oidcIssuerEnv)
+}
+
+// OIDCClient returns the client id and secret registered at the OpenID Connect provider.
+func OIDCClient() (id, secret string) {
+	return os.Getenv(oidcClientIDEnv), os.Getenv(oidcClientSecretEnv)
+}
+
+// OIDCScopes returns the scopes to request, separated by commas in OIDC_SCOPES.
+func OIDCScopes() []string {
+	scopes := os.Getenv(oidcScopesEnv)
+	if scopes == "" {
+		scopes = defaultOIDCScopes
+	}
+	var res []string
+	for _, s := range strings.Split(scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// OIDCClaims returns the id token claims naming the user and listing their groups.
+func OIDCClaims() (user, groups string) {
+	user, groups = os.Getenv(oidcUserClaimEnv), os.Getenv(oidcGroupsClaimEnv)
+	if user == "" {
+		user = defaultUserClaim
+	}
+	if groups == "" {
+		groups = defaultGroupsClaim
+	}
+	return user, groups
+}
+
+// OIDCRedirectURL returns the callback URL registered at the provider,
+// derived from the requests if empty.
+func OIDCRedirectURL() string {
+	return os.Getenv(oidcRedirectURLEnv)
+}
+
+// ProxyHeaders returns the request headers carrying the user name and groups set by the proxy.
+func ProxyHeaders() (user, groups string) {
+	user, groups = os.Getenv(proxyUserHeaderEnv), os.Getenv(proxyGroupsHeaderEnv)
+	if user == "" {
+		user = defaultUserHeader
+	}
+	if groups == "" {
+		groups = defaultGroupHeader
+	}
+	return user, groups
+}
+
+// ProxyTrustedCIDRs returns the networks of the proxies whose identity headers are trusted.
+func ProxyTrustedCIDRs() []string {
+	return listEnv(proxyTrustedEnv)
+}
+
+// TrustedProxies returns the addresses and networks of the proxies whose X-Forwarded-For gives the client IP,
+// none by default. It is apart from ProxyTrustedCIDRs: a load balancer may forward the client IP
+// without authenticating anyone.
+func TrustedProxies() []string {
+	return listEnv(trustedProxiesEnv)
+}
+
+// listEnv returns the items of the env name separated by commas.
+func listEnv(name string) []string {
+	var items []string
+	for _, s := range strings.Split(os.Getenv(name), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+// PolicyFile returns the file of the access policy, everyone may read and write if empty.
+func PolicyFile() string {
+	return os.Getenv(policyFileEnv)
+}
+
+// AuditLog returns where the audit records are written: AuditStdout, the default, AuditNone or a file.
+func AuditLog() string {
+	if sink := os.Getenv(auditLogEnv); sink != "" {
+		return sink
+	}
+	return AuditStdout
+}
+
+// AuditRotation returns the size in bytes the audit file is rotated at, 0 not to rotate it, and the number of rotated files kept.
+func AuditRotation() (maxSize int64, backups int) {
+	return int64(intEnv(auditMaxSizeEnv, defaultAuditMaxMB)) << 20, intEnv(auditBackupsEnv, defaultAuditBackup)
+}
+
+// AuditKeep returns the number of audit records kept in memory to be queried.
+func AuditKeep() int {
+	return intEnv(auditKeepEnv, defaultAuditKeep)
+}
+
+// ViewMaxSize returns the most bytes of a file read at once to be viewed.
+func ViewMaxSize() int64 {
+	return int64(intEnv(viewMaxSizeEnv, defaultViewMaxKB)) << 10
+}
+
+// intEnv returns the non-negative integer in the env variable name, or def if unset or invalid.
+func intEnv(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		slog.Warn("invalid env, using the default", slog.String("name", name), slog.String("value", v), slog.Int("default", def))
+		return def
+	}
+	return n
+}
+
+// SessionStore returns where the sessions are kept: SessionMemory, the default, SessionFile or SessionRedis.
+func SessionStore() string {
+	if s := os.Getenv(sessionStoreEnv); s != "" {
+		return s
+	}
+	return SessionMemory
+}
+
+// SessionDir returns the directory of the sessions kept in files.
+func SessionDir() string {
+	return os.Getenv(sessionDirEnv)
+}
+
+// SessionRedisURL returns the url of the Redis server keeping the sessions, such as redis://:password@host:6379/0.
+func SessionRedisURL() string {
+	return os.Getenv(sessionRedisURLEnv)
+}
@@ -1,20 +1,30 @@
 package api
 
 import (
-	"archive/tar"
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
+	"mime"
 	"net/http"
+	"path"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/audit"
 	"github.com/zrcoder/podFiles/internal/auth"
-	"github.com/zrcoder/podFiles/internal/k8s"
+	"github.com/zrcoder/podFiles/internal/backend"
 	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/oidc"
+	"github.com/zrcoder/podFiles/internal/policy"
 	"github.com/zrcoder/podFiles/internal/state"
+	"github.com/zrcoder/podFiles/internal/user"
+	"github.com/zrcoder/podFiles/internal/util/fspath"
 	"github.com/zrcoder/podFiles/internal/util/log"
 )
 
@@ -22,11 +32,13 @@ const (
 	Prefix = "/api/"
 
 	loginPath      = "login"
+	tokenLoginPath = "login/token"
 	registerPath   = "register"
 	logoutPath     = "logout"
 	unregisterPath = "unregister"
 	userPath       = "user"
 
+	clustersPath   = "clusters"
 	namespacesPath = "namespaces"
 	podsPath       = "pods"
 	containersPath = "containers"
@@ -38,11 +50,13 @@ const (
 	HealthPath = "/health"
 
 	Login      = Prefix + loginPath
+	TokenLogin = Prefix + tokenLoginPath
 	Register   = Prefix + registerPath
 	Logout     = Prefix + logoutPath
 	Unregister = Prefix + unregisterPath
 	User       = Prefix + userPath
 
+	Clusters   = Prefix + clustersPath
 	Namespaces = Prefix + namespacesPath
 	Pods       = Prefix + podsPath
 	Containers = Prefix + containersPath
@@ -51,43 +65,134 @@ const (
 	Download   = Prefix + downloadPath
 )
 
-var k8sClient *k8s.Client
+var (
+	fsBackend backend.Backend
+	// accessPolicy is nil without a policy file, letting everyone read and write
+	accessPolicy *policy.Policy
+)
+
+// Authenticators are the ways to log in, nil when disabled.
+type Authenticators struct {
+	Users  *user.Store
+	OIDC   *oidc.Provider
+	Tokens backend.TokenReviewer
+}
 
-func New() http.Handler {
+// New returns the api handler, serving the containers of b as p allows and recording the file operations to a.
+func New(b backend.Backend, auths Authenticators, p *policy.Policy, a *audit.Logger) http.Handler {
 	gin.SetMode(gin.ReleaseMode)
 
-	var err error
-	k8sClient, err = k8s.New()
-	if err != nil {
+	fsBackend = b
+	accessPolicy = p
+	auditLog = a
+	users = auths.Users
+	oidcProvider = auths.OIDC
+	tokenReviewer = auths.Tokens
+
+	g := gin.Default()
+	// the client ips, such as in the audit records, come from X-Forwarded-For of these only
+	if err := g.SetTrustedProxies(conf.TrustedProxies()); err != nil {
 		panic(err)
 	}
+	public := g.Group(Prefix)
+	{
+		if users != nil {
+			public.POST(loginPath, login)
+			public.POST(registerPath, register)
+		}
+		if tokenReviewer != nil {
+			public.POST(tokenLoginPath, tokenLogin)
+		}
+		if oidcProvider != nil {
+			public.GET(oidcLoginPath, oidcLogin)
+			public.GET(oidcCallbackPath, oidcCallback)
+		}
+		public.POST(logoutPath, logout)
+	}
 
-	g := gin.Default()
 	api := g.Group(Prefix)
 	api.Use(auth.Auth)
+	if users != nil {
+		api.Use(accountExists)
+	}
 	{
+		api.GET(userPath, currentUser)
+		if users != nil {
+			api.POST(unregisterPath, unregister)
+		}
+		api.GET(clustersPath, listClusters)
+		api.POST(clustersPath, setCluster)
 		api.GET(namespacesPath, listNamespaces)
 		api.POST(namespacesPath, setNamespace)
 		api.GET(podsPath, listPods)
 		api.POST(podsPath, setPod)
 		api.GET(containersPath, listContainers)
 		api.POST(containersPath, setContainer)
-		api.GET(filesPath, listFiles)
+		api.GET(filesPath, sessionTarget, audited(audit.OpList), listFiles)
 		api.POST(filesPath, setPath)
-		api.POST(uploadPath, upload)
-		api.POST(downloadPath, download)
+		api.POST(uploadPath, sessionTarget, audited(audit.OpUpload), upload)
+		api.POST(downloadPath, sessionTarget, audited(audit.OpDownload), download)
+		api.GET(auditPath, listAudit)
+	}
+	v2 := api.Group(v2Path)
+	{
+		v2.GET(clustersPath, listClusters)
+		v2.GET(v2NamespacesPath, listNamespacesV2)
+		v2.GET(v2PodsPath, urlTarget, listPodsV2)
+		v2.GET(v2ContainersPath, urlTarget, listContainersV2)
+		v2.GET(v2FSPath, urlTarget, audited(audit.OpList), listFilesV2)
+		v2.POST(v2FSPath, urlTarget, audited(audit.OpUpload), uploadV2)
+		v2.PUT(v2FSPath, urlTarget, audited(audit.OpEdit), editV2)
+		v2.GET(v2ArchivePath, urlTarget, audited(audit.OpDownload), downloadV2)
+		v2.GET(v2RawPath, urlTarget, audited(audit.OpRead), rawV2)
+		v2.GET(v2ViewPath, urlTarget, audited(audit.OpRead), viewV2)
+		v2.GET(v2TailPath, urlTarget, audited(audit.OpTail), tailV2)
+		v2.DELETE(v2FSPath, urlTarget, audited(audit.OpRemove), removeV2)
+		v2.POST(v2MovePath, urlTarget, audited(audit.OpRename), moveV2)
+		v2.POST(v2MkdirPath, urlTarget, audited(audit.OpMkdir), mkdirV2)
+		v2.POST(v2ChmodPath, urlTarget, audited(audit.OpChmod), chmodV2)
+		v2.POST(v2ChownPath, urlTarget, audited(audit.OpChown), chownV2)
 	}
 
 	return g
 }
 
+func listClusters(c *gin.Context) {
+	clusters, err := fsBackend.Clusters(c.Request.Context())
+	if err != nil {
+		slog.Error("list clusters", log.Error(err))
+		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, clusters)
+}
+
+func setCluster(c *gin.Context) {
+	cluster := c.Query("cluster")
+	if cluster == "" {
+		slog.Error("cluster is required")
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("cluster is required"))
+		return
+	}
+	st := sessionState(c)
+	st.SetCluster(cluster)
+	if !saveState(c, st) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
 func listNamespaces(c *gin.Context) {
-	ns, err := k8sClient.ListCommonNamespaces(c.Request.Context())
+	st := sessionState(c)
+	ns, err := fsBackend.Namespaces(c.Request.Context(), st.Target())
 	if err != nil {
 		slog.Error("list namespaces", log.Error(err))
-		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
 		return
 	}
+	ns = slices.DeleteFunc(ns, func(n models.Namespace) bool {
+		return accessPolicy.Access(st.Identity, n.Namespace) < policy.Read
+	})
 	c.JSON(http.StatusOK, ns)
 }
 
@@ -98,15 +203,25 @@ func setNamespace(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, schema.ErrorResponse("namespace is required"))
 		return
 	}
-	session := c.GetString(state.SessionKey)
-	state.Get(session).SetNamespace(namespace)
+	st := sessionState(c)
+	if !allowed(c, st.Identity, namespace, policy.Read) {
+		return
+	}
+	st.SetNamespace(namespace)
+	if !saveState(c, st) {
+		return
+	}
 	c.Status(http.StatusOK)
 }
 
 func listPods(c *gin.Context) {
 	session := c.GetString(state.SessionKey)
 	slog.Debug("list pods", slog.String("session", session))
-	pods, err := k8sClient.ListRunningPods(c.Request.Context(), session)
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
+	pods, err := fsBackend.Pods(c.Request.Context(), st.Target())
 	if err != nil {
 		slog.Error("list pods", log.Error(err))
 		c.JSON(http.StatusOK, []models.Pod{})
@@ -122,14 +237,23 @@ func setPod(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, schema.ErrorResponse("pod is required"))
 		return
 	}
-	session := c.GetString(state.SessionKey)
-	state.Get(session).SetPod(pod)
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
+	st.SetPod(pod)
+	if !saveState(c, st) {
+		return
+	}
 	c.Status(http.StatusOK)
 }
 
 func listContainers(c *gin.Context) {
-	session := c.GetString(state.SessionKey)
-	containers, err := k8sClient.ListContainers(c.Request.Context(), session)
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
+	containers, err := fsBackend.Containers(c.Request.Context(), st.Target())
 	if err != nil {
 		slog.Error("list containers", log.Error(err))
 		c.JSON(http.StatusOK, []models.Container{})
@@ -145,40 +269,109 @@ func setContainer(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "container is required"})
 		return
 	}
-	session := c.GetString(state.SessionKey)
-	state.Get(session).SetContainer(container)
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
+	st.SetContainer(container)
+	if !saveState(c, st) {
+		return
+	}
 	c.Status(http.StatusOK)
 }
 
 func listFiles(c *gin.Context) {
-	session := c.GetString(state.SessionKey)
-	st := state.Get(session)
+	st := sessionState(c)
 	if st == nil || st.Container == "" {
 		c.JSON(http.StatusBadRequest, schema.ErrorResponse("container is required"))
 		return
 	}
-	files, err := k8sClient.ListFiles(c.Request.Context(), st)
-	if err != nil {
-		slog.Error("list files", log.Error(err))
-		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+	access := accessPolicy.Access(st.Identity, st.Namespace)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
+	if hidden := c.Query("hidden"); hidden != "" {
+		st.SetShowHidden(hidden == "true")
+		if !saveState(c, st) {
+			return
+		}
+	}
+	files, caps, ok := listDir(c, st.Target(), st.FSPath(), st.ShowHidden)
+	if !ok {
 		return
 	}
-	slog.Debug("list files", "files", files)
 	c.JSON(http.StatusOK, schema.SuccessResponse("success", schema.Schema{
-		"files": files,
-		"breadItems": []models.BreadcrumbItem{
-			{Label: st.Namespace},
-			{Label: st.Pod},
-			{Label: st.Container},
-			{Label: st.FSPath()},
-		},
-		"inSubDir": st.InSubDir(),
+		"files":        files,
+		"breadItems":   breadItems(st.Target(), st.FSPath(), st.ShowHidden),
+		"inSubDir":     st.InSubDir(),
+		"showHidden":   st.ShowHidden,
+		"capabilities": caps,
+		"writable":     access >= policy.Write,
 	}))
 }
 
+// listDir lists the files of dir in the container t which the path rules don't hide,
+// with the capabilities of the container, answering the error if any.
+func listDir(c *gin.Context, t models.Target, dir string, showHidden bool) ([]models.FileInfo, *models.Capabilities, bool) {
+	pf, ok := pathFilter(c, t)
+	if !ok {
+		return nil, nil, false
+	}
+	realDir, ok := allowedPath(c, pf, dir)
+	if !ok {
+		return nil, nil, false
+	}
+	files, err := fsBackend.List(c.Request.Context(), t, dir)
+	if err != nil {
+		slog.Error("list files", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return nil, nil, false
+	}
+	files = backend.FilterHidden(files, showHidden)
+	files = filterDenied(files, pf, dir, realDir)
+	for i := range files {
+		files[i].Perm = models.Perm(files[i].Mode)
+	}
+	slog.Debug("list files", "files", files)
+	caps, err := fsBackend.Capabilities(c.Request.Context(), t)
+	if err != nil {
+		// the listing is still useful without them
+		slog.Warn("probe capabilities", log.Error(err))
+		caps = &models.Capabilities{}
+	}
+	return files, caps, true
+}
+
+// breadItems links to the cluster, namespace and pod of t on the home page,
+// and to its container and every directory above dir on the files page, showing the dotfiles if hidden.
+func breadItems(t models.Target, dir string, hidden bool) []models.BreadcrumbItem {
+	var items []models.BreadcrumbItem
+	if t.Cluster != "" {
+		items = append(items, models.BreadcrumbItem{Label: t.Cluster, Href: auth.HomeLink(models.Target{Cluster: t.Cluster}, hidden)})
+	}
+	items = append(items,
+		models.BreadcrumbItem{Label: t.Namespace, Href: auth.HomeLink(models.Target{Cluster: t.Cluster, Namespace: t.Namespace}, hidden)},
+		models.BreadcrumbItem{Label: t.Pod, Href: auth.HomeLink(t, hidden)},
+		models.BreadcrumbItem{Label: t.Container, Href: auth.FilesLink(t, "/", hidden)},
+	)
+	p := "/"
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		p = path.Join(p, name)
+		items = append(items, models.BreadcrumbItem{Label: name, Href: auth.FilesLink(t, p, hidden)})
+	}
+	// the current directory links nowhere
+	items[len(items)-1].Href = ""
+	return items
+}
+
 func setPath(c *gin.Context) {
-	session := c.GetString(state.SessionKey)
-	st := state.Get(session)
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
 	back := c.Query("back")
 	if back == "true" {
 		popPath(c, st)
@@ -194,21 +387,54 @@ func popPath(c *gin.Context, st *models.State) {
 		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
 		return
 	}
+	saveState(c, st)
 }
 
 func appendPath(c *gin.Context, st *models.State) {
-	dir := strings.TrimRight(c.Query("dir"), "/")
-	if dir == "" {
-		c.JSON(http.StatusBadRequest, schema.ErrorResponse("directory is required"))
+	dir, err := fspath.Name(strings.TrimRight(c.Query("dir"), "/"))
+	if err != nil {
+		slog.Error("append path", log.Error(err))
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid directory: "+err.Error()))
 		return
 	}
 	slog.Debug("append path", slog.String("path", dir))
 
+	pf, ok := pathFilter(c, st.Target())
+	if !ok {
+		return
+	}
+	p := path.Join(st.FSPath(), dir)
+	if !pathAllowed(c, pf, p) {
+		return
+	}
+	// a link may lead to a denied directory, the listing hides them but the api can still be called
+	info, err := fsBackend.Stat(c.Request.Context(), st.Target(), p)
+	if err != nil {
+		slog.Error("append path", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	if info.LinkTarget != "" && !pathAllowed(c, pf, linkPath(st.FSPath(), info.LinkTarget)) {
+		return
+	}
+
 	st.AddPath(dir)
+	if !saveState(c, st) {
+		return
+	}
 	c.Status(http.StatusOK)
 }
 
 func upload(c *gin.Context) {
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Write) {
+		return
+	}
+	uploadTo(c, st.Target(), st.FSPath())
+}
+
+// uploadTo writes the uploaded file into the directory dir of the container t.
+func uploadTo(c *gin.Context, t models.Target, dir string) {
 	// Get the uploaded file
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -217,6 +443,19 @@ func upload(c *gin.Context) {
 		return
 	}
 
+	name, err := fspath.Name(file.Filename)
+	if err != nil {
+		slog.Error("upload file", log.Error(err))
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid file name: "+err.Error()))
+		return
+	}
+	filePath := path.Join(dir, name)
+	auditFile(c, filePath)
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, filePath) {
+		return
+	}
+
 	src, err := file.Open()
 	if err != nil {
 		slog.Error("upload file", log.Error(err))
@@ -225,73 +464,68 @@ func upload(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// Create a pipe for streaming data
-	pr, pw := io.Pipe()
-
-	// Use a goroutine to write data to the pipe
-	go func() {
-		defer pw.Close()
-
-		// Use buffered writer to reduce memory pressure
-		bufWriter := bufio.NewWriterSize(pw, k8s.FileBufferSize)
-		tw := tar.NewWriter(bufWriter)
-		defer tw.Close()
-
-		hdr := &tar.Header{
-			Name: file.Filename,
-			Mode: 0o644,
-			Size: file.Size,
-		}
-		if err := tw.WriteHeader(hdr); err != nil {
-			slog.Error("failed to write tar header", log.Error(err))
-			return
-		}
-
-		// Use a buffer for copying to control memory usage
-		buf := make([]byte, k8s.FileBufferSize)
-
-		// Use CopyBuffer instead of Copy for better memory control
-		_, err := io.CopyBuffer(tw, src, buf)
-		if err != nil {
-			slog.Error("failed to copy file data", log.Error(err))
-			return
-		}
-
-		// Ensure all data is flushed
-		if err := tw.Flush(); err != nil {
-			slog.Error("failed to flush tar writer", log.Error(err))
-			return
-		}
-
-		if err := bufWriter.Flush(); err != nil {
-			slog.Error("failed to flush buffer", log.Error(err))
-			return
-		}
-	}()
-
-	session := c.GetString(state.SessionKey)
-	st := state.Get(session)
-
 	// Upload the file to the pod
-	err = k8sClient.UploadFile(c.Request.Context(), st.Namespace, st.Pod, st.Container, st.FSPath(), pr)
+	err = fsBackend.Write(c.Request.Context(), t, filePath, src, file.Size, 0o644)
 	if err != nil {
 		slog.Error("upload file", log.Error(err))
-		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
 		return
 	}
 
+	auditBytes(c, file.Size)
 	c.JSON(http.StatusOK, schema.SuccessResponse("", schema.Schema{"value": "success"}))
 }
 
 func download(c *gin.Context) {
-	session := c.GetString(state.SessionKey)
-	file := c.Query("file")
-	file = strings.Trim(file, "/")
+	file, err := fspath.Rel(c.Query("file"))
+	if err != nil {
+		slog.Error("download file", log.Error(err))
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid file: "+err.Error()))
+		return
+	}
 	slog.Debug("download", slog.String("path", file))
 
+	st := sessionState(c)
+	if !allowed(c, st.Identity, st.Namespace, policy.Read) {
+		return
+	}
+	archive(c, st.Target(), path.Join(st.FSPath(), file))
+}
+
+// archive streams the file or directory p of the container t as a gzipped tarball.
+func archive(c *gin.Context, t models.Target, p string) {
+	auditFile(c, p)
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) {
+		return
+	}
+	info, err := fsBackend.Stat(c.Request.Context(), t, p)
+	if err != nil {
+		slog.Error("download file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	if !info.Downloadable() {
+		msg := fmt.Sprintf("%s is a %s, only files and directories can be downloaded", p, info.Type)
+		slog.Error("download file", slog.String("error", msg))
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(msg))
+		return
+	}
+	if info.Type == models.FileTypeDir && pf.Contains(p) {
+		msg := fmt.Sprintf("%s holds protected paths, download its subdirectories instead", p)
+		slog.Warn("download file", slog.String("error", msg))
+		c.JSON(http.StatusForbidden, schema.ErrorResponse(msg))
+		return
+	}
+
+	name := path.Base(p)
+	if name == "/" {
+		name = "root"
+	}
+
 	// Set response headers
 	c.Header("Content-Type", "application/octet-stream")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tgz", file))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".tgz"}))
 
 	// Set Transfer-Encoding to chunked for streaming
 	c.Header("Transfer-Encoding", "chunked")
@@ -299,11 +533,12 @@ func download(c *gin.Context) {
 	// Use Gin's Stream method for streaming response
 	c.Stream(func(w io.Writer) bool {
 		// Create a buffered writer to reduce memory pressure
-		bufWriter := bufio.NewWriterSize(w, k8s.FileBufferSize)
+		bufWriter := bufio.NewWriterSize(w, backend.FileBufferSize)
 
-		err := k8sClient.DownloadFile(c.Request.Context(), session, file, bufWriter)
+		err := fsBackend.Archive(c.Request.Context(), t, p, bufWriter)
 		if err != nil {
 			slog.Error("download file failed", log.Error(err))
+			auditError(c, err)
 			return false
 		}
 
@@ -312,6 +547,56 @@ func download(c *gin.Context) {
 
 		return false // Return false to end the stream
 	})
+	auditBytes(c, int64(max(c.Writer.Size(), 0)))
+}
+
+// sessionState returns the state of the session, as loaded by auth.Auth.
+// Its changes are kept by saveState.
+func sessionState(c *gin.Context) *models.State {
+	return c.MustGet(state.StateKey).(*models.State)
+}
+
+// saveState keeps the changes of st, answering 500 if it can't.
+func saveState(c *gin.Context, st *models.State) bool {
+	if err := state.Save(c.GetString(state.SessionKey), st); err != nil {
+		slog.Error("save session", log.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		return false
+	}
+	return true
+}
+
+// allowed reports whether id has the access need to namespace, answering 403 if not.
+func allowed(c *gin.Context, id *models.Identity, namespace string, need policy.Access) bool {
+	if accessPolicy.Access(id, namespace) >= need {
+		return true
+	}
+	user := ""
+	if id != nil {
+		user = id.User
+	}
+	msg := fmt.Sprintf("%s access to namespace %s denied", need, namespace)
+	slog.Warn("policy", slog.String("user", user), slog.String("path", c.Request.URL.Path), slog.String("error", msg))
+	c.AbortWithStatusJSON(http.StatusForbidden, schema.ErrorResponse(msg))
+	return false
+}
+
+// errorStatus maps a backend error to its http status.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, backend.ErrNoIdentity):
+		return http.StatusUnauthorized
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, backend.ErrReadOnly), errors.Is(err, fs.ErrExist), errors.Is(err, backend.ErrIsDir):
+		return http.StatusConflict
+	case errors.Is(err, backend.ErrUnknownID):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 func Healthz(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/internal/audit"
+)
+
+const (
+	auditPath = "audit"
+	Audit     = Prefix + auditPath
+
+	// set by the handlers for their audit record
+	auditPathKey  = "auditPath"
+	auditToKey    = "auditTo"
+	auditBytesKey = "auditBytes"
+	auditErrorKey = "auditError"
+
+	// maxAuditError is how much of an error response is kept for the record
+	maxAuditError     = 512
+	defaultAuditLimit = 100
+)
+
+var auditLog *audit.Logger
+
+// audited records the operation op of the handlers after it, on the target set by sessionTarget or urlTarget.
+// They may set the path, the byte count and the error with auditFile, auditBytes and auditError,
+// the path defaults to the one addressed and the error to the one answered.
+func audited(op string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		t := requestTarget(c)
+		r := audit.Record{
+			Time: start, IP: c.ClientIP(), Op: op,
+			Cluster: t.Cluster, Namespace: t.Namespace, Pod: t.Pod, Container: t.Container,
+			Path: c.GetString(targetPathKey),
+		}
+		if t.Identity != nil {
+			r.User, r.Groups = t.Identity.User, t.Identity.Groups
+		}
+		w := &errorCapture{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		r.DurationMs = time.Since(start).Milliseconds()
+		if p := c.GetString(auditPathKey); p != "" {
+			r.Path = p
+		}
+		r.To = c.GetString(auditToKey)
+		r.Bytes = c.GetInt64(auditBytesKey)
+		status := w.Status()
+		switch {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			r.Outcome = audit.OutcomeDenied
+		case status >= http.StatusBadRequest:
+			r.Outcome = audit.OutcomeError
+		default:
+			r.Outcome = audit.OutcomeOK
+		}
+		if r.Outcome != audit.OutcomeOK {
+			r.Error = w.message()
+		}
+		if err := c.GetString(auditErrorKey); err != "" {
+			r.Outcome, r.Error = audit.OutcomeError, err
+		}
+		auditLog.Log(r)
+	}
+}
+
+// auditFile sets the path of the audit record.
+func auditFile(c *gin.Context, p string) {
+	c.Set(auditPathKey, p)
+}
+
+// auditTo sets the new path of the audit record of a rename, or the new mode or owner of a chmod or chown.
+func auditTo(c *gin.Context, p string) {
+	c.Set(auditToKey, p)
+}
+
+// auditBytes sets the byte count of the audit record.
+func auditBytes(c *gin.Context, n int64) {
+	c.Set(auditBytesKey, n)
+}
+
+// auditError records the failure of an operation whose response has already begun.
+func auditError(c *gin.Context, err error) {
+	c.Set(auditErrorKey, err.Error())
+}
+
+// errorCapture keeps the start of the error responses, to record their message.
+type errorCapture struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *errorCapture) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorCapture) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorCapture) capture(b []byte) {
+	if w.Status() >= http.StatusBadRequest && len(w.body) < maxAuditError {
+		w.body = append(w.body, b[:min(len(b), maxAuditError-len(w.body))]...)
+	}
+}
+
+// message returns the message of the captured error response, or the response itself.
+func (w *errorCapture) message() string {
+	var res struct {
+		Msg string `json:"msg"`
+	}
+	if json.Unmarshal(w.body, &res) == nil && res.Msg != "" {
+		return res.Msg
+	}
+	if len(w.body) == 0 {
+		return http.StatusText(w.Status())
+	}
+	return string(w.body)
+}
+
+// listAudit answers the audit records kept in memory to the admins, filtered by the query.
+// They are the recent ones of this replica alone, the full trail of all of them is in AUDIT_LOG.
+func listAudit(c *gin.Context) {
+	id := sessionState(c).Identity
+	if !accessPolicy.IsAdmin(id) {
+		c.JSON(http.StatusForbidden, schema.ErrorResponse("only admins can read the audit records"))
+		return
+	}
+	f := audit.Filter{
+		User:      c.Query("user"),
+		Namespace: c.Query("namespace"),
+		Pod:       c.Query("pod"),
+		Op:        c.Query("op"),
+		Outcome:   c.Query("outcome"),
+		Limit:     defaultAuditLimit,
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid since, want an RFC 3339 time: "+err.Error()))
+			return
+		}
+		f.Since = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid limit: "+limit))
+			return
+		}
+		f.Limit = n
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", schema.Schema{"records": auditLog.Query(f)}))
+}
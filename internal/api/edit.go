@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+type editRequest struct {
+	Content string `json:"content" form:"content"`
+	// Version is the version of the file when it was opened, as answered by V2View
+	Version string `json:"version" form:"version" binding:"required"`
+}
+
+// editV2 replaces the content of the regular file of the url, keeping its mode and owner,
+// unless it changed since the version it was opened at, answering 409 then.
+func editV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	var req editRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	maxSize := conf.ViewMaxSize()
+	if int64(len(req.Content)) > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, schema.ErrorResponse(fmt.Sprintf("files over %d bytes can't be edited", maxSize)))
+		return
+	}
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) {
+		return
+	}
+	info, current, ok := editedFile(c, t, p, maxSize)
+	if !ok {
+		return
+	}
+	if contentVersion(current) != req.Version {
+		c.JSON(http.StatusConflict, schema.ErrorResponse(p+" changed since it was opened, open it again to edit it"))
+		return
+	}
+	// the file may still change until it is replaced, the window is as short as the write
+	if err := fsBackend.Replace(c.Request.Context(), t, p, strings.NewReader(req.Content), int64(len(req.Content)), info.Mode, info.UID, info.GID); err != nil {
+		slog.Error("edit file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	auditBytes(c, int64(len(req.Content)))
+	c.JSON(http.StatusOK, schema.SuccessResponse("", map[string]any{"version": contentVersion([]byte(req.Content))}))
+}
+
+// editedFile returns the regular file p of the container t with its current content, answering the error if any.
+func editedFile(c *gin.Context, t models.Target, p string, maxSize int64) (*models.FileInfo, []byte, bool) {
+	info, err := fsBackend.Stat(c.Request.Context(), t, p)
+	if err != nil {
+		slog.Error("edit file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return nil, nil, false
+	}
+	// the replace would swap a link for a file, the file it links to is edited instead
+	if info.Type == models.FileTypeLink {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("%s is a link to %s, edit the file it links to", p, info.LinkTarget)))
+		return nil, nil, false
+	}
+	if info.Type != models.FileTypeFile {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("%s is a %s, only files can be edited", p, info.Type)))
+		return nil, nil, false
+	}
+	if info.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, schema.ErrorResponse(fmt.Sprintf("files over %d bytes can't be edited", maxSize)))
+		return nil, nil, false
+	}
+	var buf bytes.Buffer
+	// one more byte tells whether it grew since the stat
+	if err := fsBackend.ReadRange(c.Request.Context(), t, p, 0, maxSize+1, &buf); err != nil {
+		slog.Error("edit file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return nil, nil, false
+	}
+	return info, buf.Bytes(), true
+}
+
+// contentVersion returns the version of the content of a file, its SHA-256.
+func contentVersion(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/util/fspath"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+const (
+	v2MovePath  = v2ContainerPath + "/move/*path"
+	v2MkdirPath = v2ContainerPath + "/mkdir/*path"
+	v2ChmodPath = v2ContainerPath + "/chmod/*path"
+	v2ChownPath = v2ContainerPath + "/chown/*path"
+)
+
+// V2Move returns the url renaming or moving the absolute path p of container, to the form field to.
+func V2Move(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/move" + p
+}
+
+// V2Mkdir returns the url creating the directory p in container.
+func V2Mkdir(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/mkdir" + p
+}
+
+// V2Chmod returns the url setting the mode of the absolute path p of container, to the form field mode.
+func V2Chmod(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/chmod" + p
+}
+
+// V2Chown returns the url setting the owner and the group of the absolute path p of container,
+// to the form fields owner and group.
+func V2Chown(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/chown" + p
+}
+
+type moveRequest struct {
+	// To is the new path, or a name in the same directory
+	To string `json:"to" form:"to" binding:"required"`
+}
+
+// removeV2 removes the file of the url, directories need the query parameter recursive=true.
+func removeV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	if p == "/" {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("the root directory can't be removed"))
+		return
+	}
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) || !noneProtected(c, pf, p) {
+		return
+	}
+	if err := fsBackend.Remove(c.Request.Context(), t, p, c.Query("recursive") == "true"); err != nil {
+		slog.Error("remove file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", nil))
+}
+
+// moveV2 renames or moves the file of the url, it never replaces an existing file.
+func moveV2(c *gin.Context) {
+	t, from := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	var req moveRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	to, err := destination(from, req.To)
+	if err != nil {
+		slog.Error("move file", log.Error(err))
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid destination: "+err.Error()))
+		return
+	}
+	auditTo(c, to)
+	if from == "/" || to == from || strings.HasPrefix(to, from+"/") {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("%s can't be moved to %s", from, to)))
+		return
+	}
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, from) || !pathAllowed(c, pf, to) || !noneProtected(c, pf, from) {
+		return
+	}
+	if err := fsBackend.Rename(c.Request.Context(), t, from, to); err != nil {
+		slog.Error("move file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", nil))
+}
+
+// destination returns the absolute path to, or the path of the relative to in the directory of from.
+func destination(from, to string) (string, error) {
+	if path.IsAbs(to) {
+		return fspath.Abs(to)
+	}
+	return fspath.Join(path.Dir(from), to)
+}
+
+// mkdirV2 creates the directory of the url, its parent must exist.
+func mkdirV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	if p == "/" {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("the root directory exists"))
+		return
+	}
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) {
+		return
+	}
+	if err := fsBackend.Mkdir(c.Request.Context(), t, p); err != nil {
+		slog.Error("make directory", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", nil))
+}
+
+type chmodRequest struct {
+	// Mode is octal, such as 644 or 2775
+	Mode      string `json:"mode" form:"mode" binding:"required"`
+	Recursive bool   `json:"recursive" form:"recursive"`
+}
+
+type chownRequest struct {
+	// Owner and Group are names or numeric ids, either may be empty to keep it
+	Owner     string `json:"owner" form:"owner"`
+	Group     string `json:"group" form:"group"`
+	Recursive bool   `json:"recursive" form:"recursive"`
+}
+
+// idPattern matches the user and group names and ids which chown takes, not a user:group pair nor an option.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*\$?$`)
+
+// chmodV2 sets the mode of the file of the url, and of everything below it with recursive.
+func chmodV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	var req chmodRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	mode, err := parseMode(req.Mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	auditTo(c, req.Mode)
+	if !changeAllowed(c, t, p, req.Recursive) {
+		return
+	}
+	if err := fsBackend.Chmod(c.Request.Context(), t, p, mode, req.Recursive); err != nil {
+		slog.Error("change mode", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(ownershipError(err, "change the mode of "+p)))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", nil))
+}
+
+// chownV2 sets the owner and the group of the file of the url, and of everything below it with recursive.
+func chownV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	var req chownRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	req.Owner, req.Group = strings.TrimSpace(req.Owner), strings.TrimSpace(req.Group)
+	if req.Owner == "" && req.Group == "" {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("an owner or a group is required"))
+		return
+	}
+	for _, id := range []string{req.Owner, req.Group} {
+		if id != "" && !idPattern.MatchString(id) {
+			c.JSON(http.StatusBadRequest, schema.ErrorResponse("invalid user or group: "+id))
+			return
+		}
+	}
+	auditTo(c, req.Owner+":"+req.Group)
+	if !changeAllowed(c, t, p, req.Recursive) {
+		return
+	}
+	if err := fsBackend.Chown(c.Request.Context(), t, p, req.Owner, req.Group, req.Recursive); err != nil {
+		slog.Error("change owner", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(ownershipError(err, "change the owner of "+p)))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", nil))
+}
+
+// parseMode parses the octal mode s, with the setuid, setgid and sticky bits.
+func parseMode(s string) (fs.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q, it is octal from 0 to 7777", s)
+	}
+	mode := fs.FileMode(n & 0o777)
+	if n&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if n&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if n&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode, nil
+}
+
+// changeAllowed reports whether the path rules allow to change p, and everything below it with recursive.
+func changeAllowed(c *gin.Context, t models.Target, p string, recursive bool) bool {
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) {
+		return false
+	}
+	return !recursive || noneProtected(c, pf, p)
+}
+
+// ownershipError explains the permission errors of a chmod or chown: only the owner of a file may change its mode,
+// and only root its owner, which containers running as a non-root user are not.
+func ownershipError(err error, what string) string {
+	if errors.Is(err, fs.ErrPermission) {
+		return fmt.Sprintf("not permitted to %s: only its owner may change its mode and only root its owner, "+
+			"and the container may run as a non-root user", what)
+	}
+	if errors.Is(err, backend.ErrUnknownID) {
+		return fmt.Sprintf("can't %s: %s", what, err)
+	}
+	return err.Error()
+}
+
+// noneProtected reports whether no protected path is below p or its real path, answering 403 if one is.
+func noneProtected(c *gin.Context, pf *paths, p string) bool {
+	real, err := realPath(c.Request.Context(), pf.t, p)
+	if err != nil {
+		slog.Error("resolve path", slog.String("path", p), log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return false
+	}
+	if !pf.Contains(p) && !pf.Contains(real) {
+		return true
+	}
+	slog.Warn("path denied", slog.String("path", p), slog.String("ip", c.ClientIP()))
+	c.AbortWithStatusJSON(http.StatusForbidden, schema.ErrorResponse(p+" holds protected paths"))
+	return false
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/auth"
+	"github.com/zrcoder/podFiles/internal/oidc"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+const (
+	oidcLoginPath    = "oidc/login"
+	oidcCallbackPath = "oidc/callback"
+
+	OIDCLogin = Prefix + oidcLoginPath
+
+	// oidcStateCookie binds a login to the browser that started it
+	oidcStateCookie = "podfiles_oidc_state"
+	// oidcNextCookie keeps the page to go back to after the login
+	oidcNextCookie  = "podfiles_oidc_next"
+	oidcStateMaxAge = 10 * 60
+)
+
+// oidcProvider is nil when OpenID Connect is disabled.
+var oidcProvider *oidc.Provider
+
+func oidcLogin(c *gin.Context) {
+	authURL, state, err := oidcProvider.AuthCodeURL(oidcRedirectURL(c))
+	if err != nil {
+		slog.Error("oidc login", log.Error(err))
+		loginFailed(c, err.Error())
+		return
+	}
+	c.SetCookie(oidcStateCookie, state, oidcStateMaxAge, Prefix+"oidc", "", false, true)
+	if next := c.Query(auth.NextParam); auth.LocalPath(next) {
+		c.SetCookie(oidcNextCookie, next, oidcStateMaxAge, Prefix+"oidc", "", false, true)
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func oidcCallback(c *gin.Context) {
+	if msg := c.Query("error"); msg != "" {
+		slog.Warn("oidc callback", slog.String("error", msg), slog.String("description", c.Query("error_description")))
+		loginFailed(c, msg)
+		return
+	}
+	state := c.Query("state")
+	cookie, err := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, Prefix+"oidc", "", false, true)
+	if err != nil || state == "" || cookie != state {
+		slog.Warn("oidc callback from another browser", slog.String("ip", c.ClientIP()))
+		loginFailed(c, oidc.ErrState.Error())
+		return
+	}
+	id, err := oidcProvider.Exchange(c.Request.Context(), state, c.Query("code"))
+	if err != nil {
+		slog.Warn("oidc callback", log.Error(err))
+		loginFailed(c, err.Error())
+		return
+	}
+	if err := auth.Login(c, id); err != nil {
+		slog.Error("oidc login", log.Error(err))
+		loginFailed(c, err.Error())
+		return
+	}
+	next, err := c.Cookie(oidcNextCookie)
+	c.SetCookie(oidcNextCookie, "", -1, Prefix+"oidc", "", false, true)
+	if err != nil || !auth.LocalPath(next) {
+		next = "/"
+	}
+	c.Redirect(http.StatusFound, next)
+}
+
+// oidcRedirectURL returns the callback url, as configured or as reached by the browser.
+func oidcRedirectURL(c *gin.Context) string {
+	if u := conf.OIDCRedirectURL(); u != "" {
+		return u
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + Prefix + oidcCallbackPath
+}
+
+// loginFailed sends the browser back to the login page, showing msg.
+func loginFailed(c *gin.Context, msg string) {
+	c.Redirect(http.StatusFound, auth.LoginPage+"?error="+url.QueryEscape(msg))
+}
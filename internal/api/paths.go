@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+// paths are the denied paths of the container t.
+// A path is checked as it is and as the file it really is in t, once its symlinks are resolved,
+// since a symlinked parent such as /app/conf -> /run/secrets/app leads into denied directories.
+type paths struct {
+	*policy.PathFilter
+	t models.Target
+}
+
+// pathFilter returns the denied paths of the container t, answering the error if any.
+func pathFilter(c *gin.Context, t models.Target) (*paths, bool) {
+	info, err := fsBackend.Pod(c.Request.Context(), t)
+	if err != nil {
+		slog.Error("describe pod", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return nil, false
+	}
+	return &paths{PathFilter: accessPolicy.Paths(t.Namespace, info.Labels, info.SecretMounts), t: t}, true
+}
+
+// pathAllowed reports whether p and its real path are not denied by pf, answering 403 if one is.
+func pathAllowed(c *gin.Context, pf *paths, p string) bool {
+	_, ok := allowedPath(c, pf, p)
+	return ok
+}
+
+// allowedPath is pathAllowed returning the real path of p.
+func allowedPath(c *gin.Context, pf *paths, p string) (string, bool) {
+	if pf.Denied(p) {
+		slog.Warn("path denied", slog.String("path", p), slog.String("ip", c.ClientIP()))
+		c.AbortWithStatusJSON(http.StatusForbidden, schema.ErrorResponse(p+" is protected"))
+		return "", false
+	}
+	real, err := realPath(c.Request.Context(), pf.t, p)
+	if err != nil {
+		slog.Error("resolve path", slog.String("path", p), log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return "", false
+	}
+	if real != p && pf.Denied(real) {
+		slog.Warn("path denied", slog.String("path", p), slog.String("real", real), slog.String("ip", c.ClientIP()))
+		c.AbortWithStatusJSON(http.StatusForbidden, schema.ErrorResponse(p+" leads to a protected path"))
+		return "", false
+	}
+	return real, true
+}
+
+// realPath returns p with its symlinks resolved in the container t.
+// The missing end of p, such as the name of a file to create, is kept as is.
+func realPath(ctx context.Context, t models.Target, p string) (string, error) {
+	p = path.Clean(p)
+	rest := ""
+	for {
+		real, err := fsBackend.RealPath(ctx, t, p)
+		if err == nil {
+			return path.Join(real, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) || p == "/" {
+			return "", err
+		}
+		p, rest = path.Dir(p), path.Join(path.Base(p), rest)
+	}
+}
+
+// filterDenied hides the denied files of dir, and the links to denied paths,
+// also looking at them from realDir, the real path of dir.
+func filterDenied(files []models.FileInfo, pf *paths, dir, realDir string) []models.FileInfo {
+	dirs := []string{dir}
+	if realDir != dir {
+		dirs = append(dirs, realDir)
+	}
+	return slices.DeleteFunc(files, func(f models.FileInfo) bool {
+		for _, d := range dirs {
+			if pf.Denied(path.Join(d, f.Name)) || f.LinkTarget != "" && pf.Denied(linkPath(d, f.LinkTarget)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// linkPath returns the path a link in dir to target leads to.
+func linkPath(dir, target string) string {
+	if path.IsAbs(target) {
+		return path.Clean(target)
+	}
+	return path.Join(dir, target)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+const (
+	v2TailPath = v2ContainerPath + "/tail/*path"
+
+	defaultTailLines = 100
+	maxTailLines     = 10000
+	// tailHeartbeat keeps the idle event streams open through proxies, and finds the clients gone
+	tailHeartbeat = 15 * time.Second
+	// maxLineLen cuts the longer lines, such as the ones of binary files
+	maxLineLen = 64 << 10
+)
+
+// V2Tail returns the url following the file p of container, from its last lines in the query parameter lines.
+func V2Tail(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/tail" + p
+}
+
+// tailV2 streams the last lines of the file of the url, then the lines appended to it,
+// keeping only the ones containing the query parameter filter if any.
+// The clients accepting text/event-stream get an event per line, the others plain lines.
+// The stream, with the exec following the file in the container, ends when the client leaves.
+func tailV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	if !allowed(c, t.Identity, t.Namespace, policy.Read) {
+		return
+	}
+	lines, err := queryInt(c, "lines", defaultTailLines)
+	if err != nil || lines > maxTailLines {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("invalid lines %q, up to %d", c.Query("lines"), maxTailLines)))
+		return
+	}
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) {
+		return
+	}
+	info, err := fsBackend.Stat(c.Request.Context(), t, p)
+	if err != nil {
+		slog.Error("tail file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	// logs are often links to the current file
+	if info.Type != models.FileTypeFile && (info.Type != models.FileTypeLink || info.LinkDir) {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("%s is a %s, only files can be followed", p, info.Type)))
+		return
+	}
+	// tail follows the link, its target must be allowed as well
+	if info.LinkTarget != "" && !pathAllowed(c, pf, linkPath(path.Dir(p), info.LinkTarget)) {
+		return
+	}
+	filter := c.Query("filter")
+	events := strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	pr, pw := io.Pipe()
+	// unblocks the tail writing to the pipe once the client left
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(fsBackend.Tail(ctx, t, p, int(lines), pw))
+	}()
+	lineCh, errCh := make(chan string), make(chan error, 1)
+	go func() {
+		r := bufio.NewReaderSize(pr, backend.FileBufferSize)
+		for {
+			line, err := readLine(r)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			select {
+			case lineCh <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if events {
+		c.Header("Content-Type", "text/event-stream")
+		// no buffering by nginx
+		c.Header("X-Accel-Buffering", "no")
+	} else {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Header("X-Content-Type-Options", "nosniff")
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(tailHeartbeat)
+	defer heartbeat.Stop()
+	defer func() { auditBytes(c, int64(max(c.Writer.Size(), 0))) }()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-lineCh:
+			if filter != "" && !strings.Contains(line, filter) {
+				continue
+			}
+			if events {
+				// a carriage return would end the data of the event
+				_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", strings.ReplaceAll(line, "\r", ""))
+			} else {
+				_, err = io.WriteString(c.Writer, line+"\n")
+			}
+		case <-heartbeat.C:
+			if !events {
+				continue
+			}
+			_, err = io.WriteString(c.Writer, ": keep-alive\n\n")
+		case err := <-errCh:
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			slog.Error("tail file", log.Error(err))
+			auditError(c, err)
+			if events {
+				fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", strings.ReplaceAll(err.Error(), "\n", " "))
+			}
+			return
+		}
+		if err != nil {
+			// the client left
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// readLine returns the next line of r without its line break, cut at maxLineLen bytes.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		line = append(line, frag[:min(len(frag), maxLineLen-len(line))]...)
+		switch {
+		case err == nil:
+			return strings.TrimRight(string(line), "\r\n"), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		default:
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/internal/auth"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+// tokenReviewer is nil when the token login is disabled.
+var tokenReviewer backend.TokenReviewer
+
+type tokenCredentials struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// tokenLogin logs in the user of a Kubernetes bearer token.
+func tokenLogin(c *gin.Context) {
+	var req tokenCredentials
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	id, err := tokenReviewer.ReviewToken(c.Request.Context(), req.Token)
+	if errors.Is(err, backend.ErrInvalidToken) {
+		slog.Warn("token login failed", slog.String("ip", c.ClientIP()))
+		c.JSON(http.StatusUnauthorized, schema.ErrorResponse(err.Error()))
+		return
+	}
+	if err != nil {
+		slog.Error("token login", log.Error(err))
+		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		return
+	}
+	if err := auth.Login(c, id); err != nil {
+		slog.Error("token login", log.Error(err))
+		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", id))
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/auth"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/user"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+// users is nil when the local accounts are disabled.
+var users *user.Store
+
+type credentials struct {
+	Name     string `json:"name" form:"name"`
+	Password string `json:"password" form:"password"`
+	// Admin is only honored when an admin registers the user
+	Admin bool `json:"admin" form:"admin"`
+}
+
+func login(c *gin.Context) {
+	var req credentials
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	u, err := users.Authenticate(req.Name, req.Password)
+	if err != nil {
+		slog.Warn("login failed", slog.String("user", req.Name), slog.String("ip", c.ClientIP()))
+		c.JSON(http.StatusUnauthorized, schema.ErrorResponse(err.Error()))
+		return
+	}
+	if err := auth.LoginAccount(c, u.Identity()); err != nil {
+		slog.Error("login", log.Error(err))
+		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("", u.Identity()))
+}
+
+func logout(c *gin.Context) {
+	auth.Logout(c)
+	c.Status(http.StatusOK)
+}
+
+func register(c *gin.Context) {
+	var req credentials
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	}
+	admin := false
+	if st := auth.Session(c.Request); st != nil && st.Identity != nil {
+		admin = accessPolicy.IsAdmin(st.Identity)
+	}
+	if !admin && !conf.SignUpEnabled() {
+		c.JSON(http.StatusForbidden, schema.ErrorResponse("only admins can register users"))
+		return
+	}
+	err := users.Register(req.Name, req.Password, admin && req.Admin)
+	switch {
+	case errors.Is(err, user.ErrInvalidName), errors.Is(err, user.ErrInvalidPassword):
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return
+	case errors.Is(err, user.ErrExists):
+		c.JSON(http.StatusConflict, schema.ErrorResponse(err.Error()))
+		return
+	case err != nil:
+		slog.Error("register", log.Error(err))
+		c.JSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+		return
+	}
+	slog.Info("register", slog.String("user", req.Name), slog.Bool("byAdmin", admin))
+	c.JSON(http.StatusOK, schema.SuccessResponse("", schema.Schema{"name": req.Name}))
+}
+
+// unregister removes the account of the session, or with an admin session, the one of the user query.
+func unregister(c *gin.Context) {
+	id := sessionState(c).Identity
+	if id == nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("anonymous sessions have no account"))
+		return
+	}
+	name := c.Query("user")
+	if name == "" {
+		name = id.User
+	}
+	if name != id.User && !accessPolicy.IsAdmin(id) {
+		c.JSON(http.StatusForbidden, schema.ErrorResponse("only admins can remove other users"))
+		return
+	}
+	if name == conf.AdminUser() {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse("the bootstrap admin can't be removed"))
+		return
+	}
+	if err := users.Remove(name); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, user.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, schema.ErrorResponse(err.Error()))
+		return
+	}
+	slog.Info("unregister", slog.String("user", name), slog.String("by", id.User))
+	if name == id.User {
+		auth.Logout(c)
+	}
+	c.Status(http.StatusOK)
+}
+
+// accountExists ends the sessions of the local accounts removed since their login.
+func accountExists(c *gin.Context) {
+	st := sessionState(c)
+	if !st.Account || st.Identity == nil {
+		c.Next()
+		return
+	}
+	if users.Removed(st.Identity.User) {
+		slog.Info("session of a removed account", slog.String("user", st.Identity.User), slog.String("ip", c.ClientIP()))
+		auth.Logout(c)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, schema.ErrorResponse("login required"))
+		return
+	}
+	c.Next()
+}
+
+func currentUser(c *gin.Context) {
+	res := models.Identity{}
+	if id := sessionState(c).Identity; id != nil {
+		res = *id
+		res.Admin = accessPolicy.IsAdmin(id)
+	}
+	c.JSON(http.StatusOK, res)
+}
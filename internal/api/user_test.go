@@ -0,0 +1,79 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/audit"
+	"github.com/zrcoder/podFiles/internal/backend/local"
+	"github.com/zrcoder/podFiles/internal/state"
+	"github.com/zrcoder/podFiles/internal/user"
+)
+
+func TestUnregister(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthLocal)
+	fs, err := local.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := user.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Register("root", "root-password", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Register("alice", "alice-password", false); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(New(fs, Authenticators{Users: store}, nil, audit.New(io.Discard, 10)))
+	defer srv.Close()
+	do := func(method, url, body, session string) *http.Response {
+		r, err := http.NewRequest(method, srv.URL+Prefix+url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: session})
+		}
+		res, err := srv.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+	login := func(name, password string) string {
+		res := do(http.MethodPost, loginPath, `{"name":"`+name+`","password":"`+password+`"}`, "")
+		for _, c := range res.Cookies() {
+			if c.Name == state.SessionKey {
+				return c.Value
+			}
+		}
+		t.Fatalf("login of %s = %d without session", name, res.StatusCode)
+		return ""
+	}
+	root, alice := login("root", "root-password"), login("alice", "alice-password")
+
+	if res := do(http.MethodGet, userPath, "", alice); res.StatusCode != http.StatusOK {
+		t.Fatalf("GET user = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if res := do(http.MethodPost, unregisterPath+"?user=alice", "", root); res.StatusCode != http.StatusOK {
+		t.Fatalf("unregister = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	// the session of the removed account ends
+	if res := do(http.MethodGet, userPath, "", alice); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET user of a removed account = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+	if state.Get(alice) != nil {
+		t.Error("the session of a removed account is kept")
+	}
+	if res := do(http.MethodGet, userPath, "", root); res.StatusCode != http.StatusOK {
+		t.Errorf("GET user of another account = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
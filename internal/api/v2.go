@@ -0,0 +1,191 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/util/fspath"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+// The v2 api addresses the containers and paths in its urls instead of the session,
+// the cluster is the query parameter cluster, the default one if empty.
+const (
+	v2Path = "v2/"
+
+	v2NamespacesPath = "ns"
+	v2PodsPath       = v2NamespacesPath + "/:ns/pods"
+	v2ContainersPath = v2PodsPath + "/:pod/containers"
+	v2ContainerPath  = v2ContainersPath + "/:container"
+	v2FSPath         = v2ContainerPath + "/fs/*path"
+	v2ArchivePath    = v2ContainerPath + "/archive/*path"
+
+	V2           = Prefix + v2Path
+	V2Clusters   = V2 + clustersPath
+	V2Namespaces = V2 + v2NamespacesPath
+
+	// set by sessionTarget and urlTarget for the handlers after them
+	targetKey     = "target"
+	targetPathKey = "targetPath"
+)
+
+// V2Pods returns the url of the pods of namespace.
+func V2Pods(namespace string) string {
+	return V2Namespaces + "/" + namespace + "/pods"
+}
+
+// V2Containers returns the url of the containers of pod.
+func V2Containers(namespace, pod string) string {
+	return V2Pods(namespace) + "/" + pod + "/containers"
+}
+
+// V2Files returns the url of the absolute path p of container, listing a directory or uploading into it.
+func V2Files(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/fs" + p
+}
+
+// V2Archive returns the url downloading the absolute path p of container as a gzipped tarball.
+func V2Archive(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/archive" + p
+}
+
+// sessionTarget addresses the container and the directory of the session.
+func sessionTarget(c *gin.Context) {
+	st := sessionState(c)
+	c.Set(targetKey, st.Target())
+	c.Set(targetPathKey, st.FSPath())
+}
+
+// urlTarget addresses the container and the path of the url, answering 400 if they are invalid.
+func urlTarget(c *gin.Context) {
+	for _, p := range c.Params {
+		if p.Value == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, schema.ErrorResponse(p.Key+" is required"))
+			return
+		}
+	}
+	if raw, ok := c.Params.Get("path"); ok {
+		p, err := fspath.Abs(raw)
+		if err != nil {
+			slog.Error("url target", log.Error(err))
+			c.AbortWithStatusJSON(http.StatusBadRequest, schema.ErrorResponse("invalid path: "+err.Error()))
+			return
+		}
+		c.Set(targetPathKey, p)
+	}
+	c.Set(targetKey, models.Target{
+		Cluster:   c.Query("cluster"),
+		Namespace: c.Param("ns"),
+		Pod:       c.Param("pod"),
+		Container: c.Param("container"),
+		Identity:  sessionState(c).Identity,
+	})
+}
+
+// requestTarget returns the target set by sessionTarget or urlTarget.
+func requestTarget(c *gin.Context) models.Target {
+	return c.MustGet(targetKey).(models.Target)
+}
+
+func listNamespacesV2(c *gin.Context) {
+	id := sessionState(c).Identity
+	ns, err := fsBackend.Namespaces(c.Request.Context(), models.Target{Cluster: c.Query("cluster"), Identity: id})
+	if err != nil {
+		slog.Error("list namespaces", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	ns = slices.DeleteFunc(ns, func(n models.Namespace) bool {
+		return accessPolicy.Access(id, n.Namespace) < policy.Read
+	})
+	c.JSON(http.StatusOK, ns)
+}
+
+func listPodsV2(c *gin.Context) {
+	t := requestTarget(c)
+	if !allowed(c, t.Identity, t.Namespace, policy.Read) {
+		return
+	}
+	pods, err := fsBackend.Pods(c.Request.Context(), t)
+	if err != nil {
+		slog.Error("list pods", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, pods)
+}
+
+func listContainersV2(c *gin.Context) {
+	t := requestTarget(c)
+	if !allowed(c, t.Identity, t.Namespace, policy.Read) {
+		return
+	}
+	containers, err := fsBackend.Containers(c.Request.Context(), t)
+	if err != nil {
+		slog.Error("list containers", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, containers)
+}
+
+// listFilesV2 lists the directory of the url, with the dotfiles if the query parameter hidden is true.
+// Without it, the dotfiles are shown as last chosen in the session.
+func listFilesV2(c *gin.Context) {
+	t, dir := requestTarget(c), c.GetString(targetPathKey)
+	access := accessPolicy.Access(t.Identity, t.Namespace)
+	if !allowed(c, t.Identity, t.Namespace, policy.Read) {
+		return
+	}
+	st := sessionState(c)
+	if hidden := c.Query("hidden"); hidden != "" && (hidden == "true") != st.ShowHidden {
+		st.SetShowHidden(hidden == "true")
+		if !saveState(c, st) {
+			return
+		}
+	}
+	showHidden := st.ShowHidden
+	files, caps, ok := listDir(c, t, dir, showHidden)
+	if !ok {
+		return
+	}
+	parent := ""
+	if dir != "/" {
+		parent = path.Dir(dir)
+	}
+	c.JSON(http.StatusOK, schema.SuccessResponse("success", schema.Schema{
+		"files": files,
+		"path":  dir,
+		// dir prefixes the file names into their paths, parent is empty at the root
+		"dir":          strings.TrimSuffix(dir, "/") + "/",
+		"parent":       parent,
+		"breadItems":   breadItems(t, dir, showHidden),
+		"showHidden":   showHidden,
+		"capabilities": caps,
+		"writable":     access >= policy.Write,
+	}))
+}
+
+// uploadV2 writes the uploaded file into the directory of the url.
+func uploadV2(c *gin.Context) {
+	t := requestTarget(c)
+	if !allowed(c, t.Identity, t.Namespace, policy.Write) {
+		return
+	}
+	uploadTo(c, t, c.GetString(targetPathKey))
+}
+
+func downloadV2(c *gin.Context) {
+	t := requestTarget(c)
+	if !allowed(c, t.Identity, t.Namespace, policy.Read) {
+		return
+	}
+	archive(c, t, c.GetString(targetPathKey))
+}
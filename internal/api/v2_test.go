@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/audit"
+	"github.com/zrcoder/podFiles/internal/backend/local"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+func TestV2(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthNone)
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "logs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "logs", "app.log"), []byte("started\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a symlinked parent leading into a denied directory
+	if err := os.MkdirAll(filepath.Join(root, "run", "secrets", "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "run", "secrets", "app", "token"), []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../run/secrets/app", filepath.Join(root, "app", "conf")); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := local.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(fs, Authenticators{}, nil, audit.New(io.Discard, 10))
+	if _, err := state.Add("v2"); err != nil {
+		t.Fatal(err)
+	}
+	// a server rather than a recorder, the downloads are streamed
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	do := func(method, url string, body io.Reader, contentType string) (int, []byte) {
+		r, err := http.NewRequest(method, srv.URL+url, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: "v2"})
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		res, err := srv.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, b
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{name: "pods", url: V2Pods(local.Name), want: http.StatusOK},
+		{name: "containers", url: V2Containers(local.Name, local.Name), want: http.StatusOK},
+		{name: "root", url: V2Files(local.Name, local.Name, local.Name, "/"), want: http.StatusOK},
+		{name: "dir", url: V2Files(local.Name, local.Name, local.Name, "/logs"), want: http.StatusOK},
+		{name: "nul", url: V2Files(local.Name, local.Name, local.Name, "/logs%00"), want: http.StatusBadRequest},
+		{name: "protected", url: V2Files(local.Name, local.Name, local.Name, "/proc"), want: http.StatusForbidden},
+		{name: "archive", url: V2Archive(local.Name, local.Name, local.Name, "/logs"), want: http.StatusOK},
+		{name: "symlinked parent", url: V2Files(local.Name, local.Name, local.Name, "/app/conf"), want: http.StatusForbidden},
+		{name: "below symlinked parent", url: V2View(local.Name, local.Name, local.Name, "/app/conf/token"), want: http.StatusForbidden},
+		{name: "archive symlinked parent", url: V2Archive(local.Name, local.Name, local.Name, "/app/conf/"), want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, b := do(http.MethodGet, tt.url, nil, ""); code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", tt.url, code, tt.want, b)
+			}
+		})
+	}
+
+	_, b := do(http.MethodGet, V2Files(local.Name, local.Name, local.Name, "/logs/"), nil, "")
+	var res struct {
+		Data struct {
+			Files []struct {
+				Name string `json:"name"`
+			} `json:"files"`
+			Path   string `json:"path"`
+			Dir    string `json:"dir"`
+			Parent string `json:"parent"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+	if d := res.Data; len(d.Files) != 1 || d.Files[0].Name != "app.log" || d.Path != "/logs" || d.Dir != "/logs/" || d.Parent != "/" {
+		t.Errorf("listing of /logs/ = %+v", d)
+	}
+
+	// the dotfiles are shown as last chosen when the url doesn't say
+	if err := os.WriteFile(filepath.Join(root, "logs", ".rotated"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{{"", 1}, {"?hidden=true", 2}, {"", 2}, {"?hidden=", 2}, {"?hidden=false", 1}, {"", 1}} {
+		_, b := do(http.MethodGet, V2Files(local.Name, local.Name, local.Name, "/logs")+tt.query, nil, "")
+		res.Data.Files = nil
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Data.Files) != tt.want {
+			t.Errorf("listing of /logs%s has %d files, want %d", tt.query, len(res.Data.Files), tt.want)
+		}
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "hello")
+	mw.Close()
+	if code, b := do(http.MethodPost, V2Files(local.Name, local.Name, local.Name, "/logs"), &body, mw.FormDataContentType()); code != http.StatusOK {
+		t.Fatalf("upload = %d: %s", code, b)
+	}
+	if b, err = os.ReadFile(filepath.Join(root, "logs", "new.txt")); err != nil || string(b) != "hello" {
+		t.Errorf("uploaded file = %q, %v", b, err)
+	}
+}
+
+func TestBreadItems(t *testing.T) {
+	target := models.Target{Cluster: "prod", Namespace: "default", Pod: "web-0", Container: "nginx"}
+	got := breadItems(target, "/var/log", true)
+	want := []models.BreadcrumbItem{
+		{Label: "prod", Href: "/?cluster=prod&hidden=true"},
+		{Label: "default", Href: "/?cluster=prod&namespace=default&hidden=true"},
+		{Label: "web-0", Href: "/?cluster=prod&namespace=default&pod=web-0&hidden=true"},
+		{Label: "nginx", Href: "/files?cluster=prod&namespace=default&pod=web-0&container=nginx&path=%2F&hidden=true"},
+		{Label: "var", Href: "/files?cluster=prod&namespace=default&pod=web-0&container=nginx&path=%2Fvar&hidden=true"},
+		{Label: "log"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("breadItems() = %+v, want %+v", got, want)
+	}
+	if got := breadItems(target, "/", false); got[len(got)-1] != (models.BreadcrumbItem{Label: "nginx"}) {
+		t.Errorf("breadItems() at the root ends with %+v", got[len(got)-1])
+	}
+}
+
+func TestV2FileOps(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthNone)
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "app.conf"), []byte("a=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := local.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(fs, Authenticators{}, nil, audit.New(io.Discard, 10))
+	if _, err := state.Add("ops"); err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, url, form string) int {
+		r := httptest.NewRequest(method, url, strings.NewReader(form))
+		r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: "ops"})
+		if form != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	url := func(f func(namespace, pod, container, p string) string, p string) string {
+		return f(local.Name, local.Name, local.Name, p)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		form   string
+		want   int
+		exists []string
+		gone   []string
+	}{
+		{name: "mkdir", method: http.MethodPost, url: url(V2Mkdir, "/conf"), want: http.StatusOK, exists: []string{"conf"}},
+		{name: "mkdir existing", method: http.MethodPost, url: url(V2Mkdir, "/conf"), want: http.StatusConflict},
+		{name: "mkdir without parent", method: http.MethodPost, url: url(V2Mkdir, "/a/b"), want: http.StatusNotFound},
+		{name: "rename", method: http.MethodPost, url: url(V2Move, "/app.conf"), form: "to=app.yaml", want: http.StatusOK,
+			exists: []string{"app.yaml"}, gone: []string{"app.conf"}},
+		{name: "move", method: http.MethodPost, url: url(V2Move, "/app.yaml"), form: "to=/conf/app.yaml", want: http.StatusOK,
+			exists: []string{"conf/app.yaml"}, gone: []string{"app.yaml"}},
+		{name: "move into itself", method: http.MethodPost, url: url(V2Move, "/conf"), form: "to=/conf/app.yaml", want: http.StatusBadRequest},
+		{name: "mkdir empty", method: http.MethodPost, url: url(V2Mkdir, "/empty"), want: http.StatusOK},
+		{name: "mkdir other", method: http.MethodPost, url: url(V2Mkdir, "/other"), want: http.StatusOK},
+		{name: "move onto an empty dir", method: http.MethodPost, url: url(V2Move, "/other"), form: "to=/empty", want: http.StatusConflict,
+			exists: []string{"other", "empty"}},
+		{name: "move onto a dir", method: http.MethodPost, url: url(V2Move, "/conf/app.yaml"), form: "to=/conf", want: http.StatusConflict},
+		{name: "move escaping", method: http.MethodPost, url: url(V2Move, "/conf/app.yaml"), form: "to=../app.yaml", want: http.StatusBadRequest},
+		{name: "move without destination", method: http.MethodPost, url: url(V2Move, "/conf/app.yaml"), want: http.StatusBadRequest},
+		{name: "move to protected", method: http.MethodPost, url: url(V2Move, "/conf/app.yaml"), form: "to=/etc/shadow", want: http.StatusForbidden},
+		{name: "chmod", method: http.MethodPost, url: url(V2Chmod, "/conf"), form: "mode=750&recursive=true", want: http.StatusOK},
+		{name: "chmod invalid", method: http.MethodPost, url: url(V2Chmod, "/conf"), form: "mode=u+x", want: http.StatusBadRequest},
+		{name: "chmod too large", method: http.MethodPost, url: url(V2Chmod, "/conf"), form: "mode=17777", want: http.StatusBadRequest},
+		{name: "chown nothing", method: http.MethodPost, url: url(V2Chown, "/conf"), want: http.StatusBadRequest},
+		{name: "chown option", method: http.MethodPost, url: url(V2Chown, "/conf"), form: "owner=-R", want: http.StatusBadRequest},
+		{name: "chown pair", method: http.MethodPost, url: url(V2Chown, "/conf"), form: "owner=root:root", want: http.StatusBadRequest},
+		{name: "chown unknown", method: http.MethodPost, url: url(V2Chown, "/conf"), form: "owner=no-such-user-podfiles", want: http.StatusBadRequest},
+		{name: "chown protected", method: http.MethodPost, url: url(V2Chown, "/"), form: "owner=0&recursive=true", want: http.StatusForbidden},
+		{name: "remove root", method: http.MethodDelete, url: url(V2Files, "/"), want: http.StatusBadRequest},
+		{name: "remove dir without recursive", method: http.MethodDelete, url: url(V2Files, "/conf"), want: http.StatusConflict, exists: []string{"conf"}},
+		{name: "remove dir", method: http.MethodDelete, url: url(V2Files, "/conf") + "?recursive=true", want: http.StatusOK, gone: []string{"conf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := do(tt.method, tt.url, tt.form); got != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.url, got, tt.want)
+			}
+			for _, p := range tt.exists {
+				if _, err := os.Stat(filepath.Join(root, p)); err != nil {
+					t.Error(err)
+				}
+			}
+			for _, p := range tt.gone {
+				if _, err := os.Stat(filepath.Join(root, p)); err == nil {
+					t.Errorf("%s still exists", p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/amisgo/schema"
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/policy"
+	"github.com/zrcoder/podFiles/internal/util/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	v2RawPath  = v2ContainerPath + "/raw/*path"
+	v2ViewPath = v2ContainerPath + "/view/*path"
+
+	// binarySniffLen is how many bytes are looked at for a NUL, as git does
+	binarySniffLen = 8000
+)
+
+// V2Raw returns the url of the content of the regular file p of container,
+// from the query parameter offset and for at most limit bytes.
+func V2Raw(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/raw" + p
+}
+
+// V2View returns the url of the content of the regular file p of container prepared to be viewed,
+// with the query parameters of V2Raw and raw=true to leave it as is.
+func V2View(namespace, pod, container, p string) string {
+	return V2Containers(namespace, pod) + "/" + container + "/view" + p
+}
+
+// rawV2 streams the content of the regular file of the url, at most conf.ViewMaxSize bytes.
+// A part of the file answers 206 with its Content-Range.
+func rawV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	info, offset, limit, ok := fileRange(c, t, p)
+	if !ok {
+		return
+	}
+	n := min(limit, info.Size-offset)
+	// never rendered by the browser, the content is the container's
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("X-Content-Type-Options", "nosniff")
+	status := http.StatusOK
+	if n < info.Size {
+		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, info.Size))
+		status = http.StatusPartialContent
+	}
+	c.Status(status)
+	if n == 0 {
+		return
+	}
+	if err := fsBackend.ReadRange(c.Request.Context(), t, p, offset, n, c.Writer); err != nil {
+		slog.Error("read file", log.Error(err))
+		auditError(c, err)
+	}
+	auditBytes(c, int64(max(c.Writer.Size(), 0)))
+}
+
+// viewV2 answers the content of the regular file of the url as text to be viewed,
+// pretty-printed if it is JSON or YAML and whole unless raw=true, or as a hex dump if it is binary.
+// A whole text file has the version to edit it with.
+func viewV2(c *gin.Context) {
+	t, p := requestTarget(c), c.GetString(targetPathKey)
+	info, offset, limit, ok := fileRange(c, t, p)
+	if !ok {
+		return
+	}
+	buf := new(bytes.Buffer)
+	if n := min(limit, info.Size-offset); n > 0 {
+		if err := fsBackend.ReadRange(c.Request.Context(), t, p, offset, n, buf); err != nil {
+			slog.Error("read file", log.Error(err))
+			c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+			return
+		}
+	}
+	auditBytes(c, int64(buf.Len()))
+	v := view(info, buf.Bytes(), offset, c.Query("raw") != "true")
+	v["limit"] = limit
+	v["max"] = conf.ViewMaxSize()
+	c.JSON(http.StatusOK, schema.SuccessResponse("", v))
+}
+
+// view returns the part b of the file from offset as it is shown,
+// a partial rune at its end is left for the next part.
+func view(info *models.FileInfo, b []byte, offset int64, pretty bool) map[string]any {
+	binary := isBinary(b)
+	if !binary && offset+int64(len(b)) < info.Size {
+		b = b[:len(b)-partialRune(b)]
+	}
+	next := offset + int64(len(b))
+	v := map[string]any{
+		"name":     info.Name,
+		"size":     info.Size,
+		"offset":   offset,
+		"next":     next,
+		"more":     next < info.Size,
+		"binary":   binary,
+		"language": "plaintext",
+		"pretty":   false,
+	}
+	if binary {
+		v["content"] = hexDump(b, offset)
+		return v
+	}
+	lang := language(info.Name)
+	v["language"], v["content"] = lang, string(b)
+	if offset > 0 || next < info.Size {
+		return v
+	}
+	// a whole text file can be edited from its raw content, and parsed
+	v["version"] = contentVersion(b)
+	if pretty {
+		if s, ok := prettyPrint(lang, b); ok {
+			v["content"], v["pretty"] = s, true
+		}
+	}
+	return v
+}
+
+// fileRange checks that the file p of the container t is a regular file which may be read,
+// and returns it with the range of the query parameters offset and limit, answering the error if any.
+func fileRange(c *gin.Context, t models.Target, p string) (*models.FileInfo, int64, int64, bool) {
+	if !allowed(c, t.Identity, t.Namespace, policy.Read) {
+		return nil, 0, 0, false
+	}
+	maxSize := conf.ViewMaxSize()
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(err.Error()))
+		return nil, 0, 0, false
+	}
+	limit, err := queryInt(c, "limit", maxSize)
+	if err != nil || limit == 0 {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("invalid limit %q", c.Query("limit"))))
+		return nil, 0, 0, false
+	}
+	limit = min(limit, maxSize)
+	pf, ok := pathFilter(c, t)
+	if !ok || !pathAllowed(c, pf, p) {
+		return nil, 0, 0, false
+	}
+	info, err := fsBackend.Stat(c.Request.Context(), t, p)
+	if err != nil {
+		slog.Error("read file", log.Error(err))
+		c.JSON(errorStatus(err), schema.ErrorResponse(err.Error()))
+		return nil, 0, 0, false
+	}
+	if info.Type != models.FileTypeFile {
+		c.JSON(http.StatusBadRequest, schema.ErrorResponse(fmt.Sprintf("%s is a %s, only files can be viewed", p, info.Type)))
+		return nil, 0, 0, false
+	}
+	if offset > 0 && offset >= info.Size {
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, schema.ErrorResponse(fmt.Sprintf("%s has %d bytes", p, info.Size)))
+		return nil, 0, 0, false
+	}
+	return info, offset, limit, true
+}
+
+// queryInt returns the non-negative integer of the query parameter name, def if it is absent.
+func queryInt(c *gin.Context, name string, def int64) (int64, error) {
+	s := c.Query(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return n, nil
+}
+
+// isBinary reports whether b is not text: it has a NUL or isn't UTF-8.
+func isBinary(b []byte) bool {
+	if bytes.IndexByte(b[:min(len(b), binarySniffLen)], 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(b[:len(b)-partialRune(b)])
+}
+
+// partialRune returns the length of the start of a rune which b ends with, if any.
+func partialRune(b []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// hexDump returns b as hexdump -C prints it, the addresses starting at offset.
+func hexDump(b []byte, offset int64) string {
+	var sb strings.Builder
+	for i := 0; i < len(b); i += 16 {
+		line := b[i:min(i+16, len(b))]
+		fmt.Fprintf(&sb, "%08x  ", offset+int64(i))
+		for j := 0; j < 16; j++ {
+			if j < len(line) {
+				fmt.Fprintf(&sb, "%02x ", line[j])
+			} else {
+				sb.WriteString("   ")
+			}
+			if j == 7 {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(" |")
+		for _, c := range line {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			sb.WriteByte(c)
+		}
+		sb.WriteString("|\n")
+	}
+	return sb.String()
+}
+
+// languages maps the file extensions to the languages highlighted by the viewer.
+var languages = map[string]string{
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".xml": "xml", ".html": "html", ".htm": "html",
+	".css": "css", ".js": "javascript", ".mjs": "javascript", ".ts": "typescript", ".py": "python",
+	".go": "go", ".java": "java", ".rb": "ruby", ".php": "php", ".rs": "rust", ".lua": "lua",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".sh": "shell", ".bash": "shell", ".sql": "sql",
+	".md": "markdown", ".ini": "ini", ".conf": "ini", ".cfg": "ini", ".toml": "ini", ".properties": "ini",
+}
+
+// language returns the language of the file name, plaintext if unknown.
+func language(name string) string {
+	if name == "Dockerfile" || strings.HasSuffix(name, ".dockerfile") {
+		return "dockerfile"
+	}
+	if lang, ok := languages[strings.ToLower(path.Ext(name))]; ok {
+		return lang
+	}
+	return "plaintext"
+}
+
+// prettyPrint indents b if it is JSON or YAML, keeping the comments and the order of YAML.
+func prettyPrint(lang string, b []byte) (string, bool) {
+	switch lang {
+	case "json":
+		buf := new(bytes.Buffer)
+		if err := json.Indent(buf, b, "", "  "); err != nil {
+			return "", false
+		}
+		return buf.String() + "\n", true
+	case "yaml":
+		buf := new(bytes.Buffer)
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		for {
+			var doc yaml.Node
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				break
+			}
+			if err != nil || enc.Encode(&doc) != nil {
+				return "", false
+			}
+		}
+		if enc.Close() != nil {
+			return "", false
+		}
+		return buf.String(), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/audit"
+	"github.com/zrcoder/podFiles/internal/backend/local"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+func TestView(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		offset  int64
+		size    int64
+		pretty  bool
+		want    map[string]any
+	}{
+		{
+			name: "app.json", content: `{"a":[1,2]}`, pretty: true,
+			want: map[string]any{"language": "json", "pretty": true, "content": "{\n  \"a\": [\n    1,\n    2\n  ]\n}\n"},
+		},
+		{
+			name: "app.json", content: `{"a":[1,2]}`,
+			want: map[string]any{"language": "json", "pretty": false, "content": `{"a":[1,2]}`},
+		},
+		{
+			name: "values.yaml", content: "# replicas\nreplicas:    3\nimage:\n    tag: v1\n", pretty: true,
+			want: map[string]any{"language": "yaml", "pretty": true, "content": "# replicas\nreplicas: 3\nimage:\n  tag: v1\n"},
+		},
+		{
+			name: "broken.json", content: `{"a":`, pretty: true,
+			want: map[string]any{"pretty": false, "content": `{"a":`},
+		},
+		{
+			// a part isn't pretty-printed, and leaves the partial rune for the next one
+			name: "app.json", content: "{\"a\":\"\xe4\xbd", size: 20, pretty: true,
+			want: map[string]any{"pretty": false, "binary": false, "content": `{"a":"`, "next": int64(6), "more": true},
+		},
+		{
+			name: "app.bin", content: "ELF\x00\x01", offset: 16, size: 21,
+			want: map[string]any{"binary": true, "language": "plaintext", "content": "00000010  45 4c 46 00 01                                    |ELF..|\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.content))
+			}
+			got := view(&models.FileInfo{Name: tt.name, Size: size}, []byte(tt.content), tt.offset, tt.pretty)
+			for k, want := range tt.want {
+				if got[k] != want {
+					t.Errorf("view()[%q] = %#v, want %#v", k, got[k], want)
+				}
+			}
+		})
+	}
+}
+
+func TestV2Raw(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthNone)
+	t.Setenv("VIEW_MAX_SIZE", "1")
+	root := t.TempDir()
+	content := strings.Repeat("0123456789", 150)
+	if err := os.WriteFile(filepath.Join(root, "app.log"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := local.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(fs, Authenticators{}, nil, audit.New(io.Discard, 10))
+	if _, err := state.Add("raw"); err != nil {
+		t.Fatal(err)
+	}
+	url := func(f func(namespace, pod, container, p string) string, p string) string {
+		return f(local.Name, local.Name, local.Name, p)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		want   int
+		body   string
+		ranged string
+	}{
+		{name: "capped", url: url(V2Raw, "/app.log"), want: http.StatusPartialContent, body: content[:1024], ranged: "bytes 0-1023/1500"},
+		{name: "range", url: url(V2Raw, "/app.log") + "?offset=1490&limit=100", want: http.StatusPartialContent, body: content[1490:], ranged: "bytes 1490-1499/1500"},
+		{name: "beyond", url: url(V2Raw, "/app.log") + "?offset=1500", want: http.StatusRequestedRangeNotSatisfiable},
+		{name: "invalid offset", url: url(V2Raw, "/app.log") + "?offset=-1", want: http.StatusBadRequest},
+		{name: "dir", url: url(V2Raw, "/"), want: http.StatusBadRequest},
+		{name: "missing", url: url(V2Raw, "/none"), want: http.StatusNotFound},
+		{name: "view", url: url(V2View, "/app.log") + "?limit=10", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: "raw"})
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.url, w.Code, tt.want, w.Body)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("GET %s = %q, want %q", tt.url, w.Body, tt.body)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.ranged {
+				t.Errorf("Content-Range = %q, want %q", got, tt.ranged)
+			}
+		})
+	}
+}
+
+func TestV2Edit(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthNone)
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "app.conf"), []byte("a=1\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("app.conf", filepath.Join(root, "link.conf")); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := local.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(fs, Authenticators{}, nil, audit.New(io.Discard, 10))
+	if _, err := state.Add("edit"); err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: "edit"})
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	file := V2Files(local.Name, local.Name, local.Name, "/app.conf")
+
+	var res struct {
+		Data struct {
+			Version string `json:"version"`
+		} `json:"data"`
+	}
+	w := do(http.MethodGet, V2View(local.Name, local.Name, local.Name, "/app.conf")+"?raw=true", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Data.Version == "" {
+		t.Fatalf("view = %s, %v, want a version", w.Body, err)
+	}
+	opened := res.Data.Version
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+		want int
+	}{
+		{name: "save", url: file, body: `{"content":"a=2\n","version":"` + opened + `"}`, want: http.StatusOK},
+		{name: "changed since opened", url: file, body: `{"content":"a=3\n","version":"` + opened + `"}`, want: http.StatusConflict},
+		{name: "without version", url: file, body: `{"content":"a=3\n"}`, want: http.StatusBadRequest},
+		{name: "dir", url: V2Files(local.Name, local.Name, local.Name, "/"), body: `{"content":"","version":"x"}`, want: http.StatusBadRequest},
+		{name: "missing", url: V2Files(local.Name, local.Name, local.Name, "/none"), body: `{"content":"","version":"x"}`, want: http.StatusNotFound},
+		{name: "link", url: V2Files(local.Name, local.Name, local.Name, "/link.conf"), body: `{"content":"a=3\n","version":"` + opened + `"}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(http.MethodPut, tt.url, tt.body); w.Code != tt.want {
+				t.Errorf("PUT %s = %d, want %d: %s", tt.url, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	b, err := os.ReadFile(filepath.Join(root, "app.conf"))
+	if err != nil || string(b) != "a=2\n" {
+		t.Errorf("content = %q, %v, want the first save", b, err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "app.conf")); err != nil || fi.Mode() != 0o640 {
+		t.Errorf("mode after the save = %v, %v, want it kept", fi.Mode(), err)
+	}
+	if fi, err := os.Lstat(filepath.Join(root, "link.conf")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link after the saves = %v, %v, want it kept", fi, err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 2 {
+		t.Errorf("the save left %d files", len(entries))
+	}
+}
+
+func TestV2Tail(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthNone)
+	root := t.TempDir()
+	file := filepath.Join(root, "app.log")
+	if err := os.WriteFile(file, []byte("err 1\ninfo 2\nerr 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := local.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(fs, Authenticators{}, nil, audit.New(io.Discard, 10))
+	if _, err := state.Add("tail"); err != nil {
+		t.Fatal(err)
+	}
+	// a server rather than a recorder, the lines are streamed
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	get := func(url string) *http.Response {
+		r, err := http.NewRequest(http.MethodGet, srv.URL+url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: "tail"})
+		r.Header.Set("Accept", "text/event-stream")
+		res, err := srv.Client().Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	url := func(p string) string {
+		return V2Tail(local.Name, local.Name, local.Name, p)
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "run", "secrets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "run", "secrets", "token"), []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("run/secrets/token", filepath.Join(root, "token.log")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		url  string
+		want int
+	}{
+		{url: url("/"), want: http.StatusBadRequest},
+		{url: url("/app.log") + "?lines=-1", want: http.StatusBadRequest},
+		{url: url("/token.log"), want: http.StatusForbidden},
+	} {
+		res := get(tt.url)
+		res.Body.Close()
+		if res.StatusCode != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.url, res.StatusCode, tt.want)
+		}
+	}
+
+	res := get(url("/app.log") + "?lines=2&filter=err")
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	r := bufio.NewReader(res.Body)
+	next := func() string {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(line, "data: ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+			}
+		}
+	}
+	if got := next(); got != "err 3" {
+		t.Errorf("first event = %q, want the last matching line", got)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("info 4\nerr 5\n")
+	f.Close()
+	if got := next(); got != "err 5" {
+		t.Errorf("next event = %q, want the appended matching line", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Package audit records who did what to which files, as JSON lines written to a sink,
+// and keeps the recent records in memory to be queried.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+// Operations recorded
+const (
+	OpList     = "list"
+	OpUpload   = "upload"
+	OpEdit     = "edit"
+	OpDownload = "download"
+	OpRead     = "read"
+	OpTail     = "tail"
+	OpRemove   = "remove"
+	OpRename   = "rename"
+	OpMkdir    = "mkdir"
+	OpChmod    = "chmod"
+	OpChown    = "chown"
+)
+
+// Outcomes of the operations
+const (
+	OutcomeOK     = "ok"
+	OutcomeDenied = "denied"
+	OutcomeError  = "error"
+)
+
+type Record struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user,omitempty"`
+	Groups    []string  `json:"groups,omitempty"`
+	IP        string    `json:"ip"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	Path      string    `json:"path"`
+	Op        string    `json:"op"`
+	// To is the new path of a rename, the new mode of a chmod or the new owner:group of a chown
+	To string `json:"to,omitempty"`
+	// Bytes is the size of the file content transferred
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"durationMs"`
+	Outcome    string `json:"outcome"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Filter selects records, its zero fields select all of them.
+type Filter struct {
+	User      string
+	Namespace string
+	Pod       string
+	Op        string
+	Outcome   string
+	Since     time.Time
+	// Limit is the maximum number of records returned, the newest ones
+	Limit int
+}
+
+func (f *Filter) match(r *Record) bool {
+	return (f.User == "" || r.User == f.User) &&
+		(f.Namespace == "" || r.Namespace == f.Namespace) &&
+		(f.Pod == "" || r.Pod == f.Pod) &&
+		(f.Op == "" || r.Op == f.Op) &&
+		(f.Outcome == "" || r.Outcome == f.Outcome) &&
+		!r.Time.Before(f.Since)
+}
+
+// Logger writes the records to its sink and keeps the last ones, it is safe for concurrent use.
+type Logger struct {
+	mu   sync.Mutex
+	sink io.Writer
+	// recent is a ring buffer, next is where the next record goes
+	recent []Record
+	next   int
+	full   bool
+}
+
+// New returns a logger writing to sink, nil to only keep the last keep records.
+func New(sink io.Writer, keep int) *Logger {
+	return &Logger{sink: sink, recent: make([]Record, keep)}
+}
+
+// Log records r.
+func (l *Logger) Log(r Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.recent) > 0 {
+		l.recent[l.next] = r
+		l.next = (l.next + 1) % len(l.recent)
+		l.full = l.full || l.next == 0
+	}
+	if l.sink == nil {
+		return
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		slog.Error("audit", log.Error(err))
+		return
+	}
+	if _, err := l.sink.Write(append(line, '\n')); err != nil {
+		slog.Error("audit", log.Error(err))
+	}
+}
+
+// Query returns the kept records matching f, the newest first.
+func (l *Logger) Query(f Filter) []Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.next
+	if l.full {
+		n = len(l.recent)
+	}
+	res := []Record{}
+	for i := 1; i <= n; i++ {
+		r := &l.recent[(l.next-i+len(l.recent))%len(l.recent)]
+		if !f.match(r) {
+			continue
+		}
+		res = append(res, *r)
+		if len(res) == f.Limit {
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	var sink bytes.Buffer
+	l := New(&sink, 3)
+	start := time.Now()
+	for i, op := range []string{OpList, OpDownload, OpUpload, OpDownload} {
+		l.Log(Record{Time: start.Add(time.Duration(i) * time.Second), User: fmt.Sprint("user", i%2), Op: op, Outcome: OutcomeOK})
+	}
+
+	lines := strings.Split(strings.TrimSpace(sink.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("sink has %d lines, want 4", len(lines))
+	}
+	var first Record
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.Op != OpList {
+		t.Errorf("first line %s: %v", lines[0], err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all kept, newest first", want: []string{"user1 download", "user0 upload", "user1 download"}},
+		{name: "by user", filter: Filter{User: "user1"}, want: []string{"user1 download", "user1 download"}},
+		{name: "by op", filter: Filter{Op: OpUpload}, want: []string{"user0 upload"}},
+		{name: "since", filter: Filter{Since: start.Add(2 * time.Second)}, want: []string{"user1 download", "user0 upload"}},
+		{name: "limit", filter: Filter{Limit: 1}, want: []string{"user1 download"}},
+		{name: "none", filter: Filter{Outcome: OutcomeDenied}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range l.Query(tt.filter) {
+				got = append(got, r.User+" "+r.Op)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Query() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeeeeeeeeeee\n", "ffff\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "ffff\n",
+		path + ".1": "eeeeeeeeeeee\n",
+		path + ".2": "cccc\ndddd\n",
+	} {
+		if got := mustRead(t, name); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups than kept: %v", err)
+	}
+
+	// reopening appends
+	f.Close()
+	f, err = OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("gg\n"))
+	f.Write([]byte("hhhh\n"))
+	if mustRead(t, path) != "hhhh\n" || mustRead(t, path+".1") != "ffff\ngg\n" {
+		t.Errorf("after reopening: %q and %q", mustRead(t, path), mustRead(t, path+".1"))
+	}
+}
+
+func TestRotatingFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	// no rotation
+	path := filepath.Join(dir, "unrotated.log")
+	f, err := OpenRotatingFile(path, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("aaaa\n"))
+	f.Write([]byte("bbbb\n"))
+	f.Close()
+	if got := mustRead(t, path); got != "aaaa\nbbbb\n" {
+		t.Errorf("unrotated file = %q", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("rotated with a max size of 0: %v", err)
+	}
+
+	// a non-empty directory in the way of the backup fails the rotation
+	path = filepath.Join(dir, "audit.log")
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err = OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range []string{"aaaa\n", "bbbbbbbb\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() after a failed rotation: %v", err)
+		}
+	}
+	if got := mustRead(t, path); got != "aaaa\nbbbbbbbb\n" {
+		t.Errorf("file after a failed rotation = %q", got)
+	}
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("cc\n")); err != nil {
+		t.Fatal(err)
+	}
+	if mustRead(t, path) != "cc\n" || mustRead(t, path+".1") != "aaaa\nbbbbbbbb\n" {
+		t.Errorf("after the next rotation: %q and %q", mustRead(t, path), mustRead(t, path+".1"))
+	}
+}
+
+func mustRead(t *testing.T, name string) string {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -6,14 +6,50 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/zrcoder/amisgo/schema"
 	"github.com/zrcoder/amisgo/util"
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
 	"github.com/zrcoder/podFiles/internal/state"
 	"github.com/zrcoder/podFiles/internal/util/log"
 )
 
+const LoginPage = "/login"
+
+// Auth lets the api calls of authenticated sessions through and rejects the others.
+// With AUTH=none, it mints anonymous sessions instead.
+// With AUTH=proxy, every call must carry the identity headers of a trusted proxy.
 func Auth(c *gin.Context) {
 	slog.Debug("auth begin")
 	s, err := c.Cookie(state.SessionKey)
+	if conf.AuthEnabled(conf.AuthNone) {
+		anonymous(c, s, err)
+		return
+	}
+	var st *models.State
+	if conf.AuthEnabled(conf.AuthProxy) {
+		s, st, err = proxySession(c.Writer, c.Request)
+		if err != nil {
+			slog.Warn("auth: proxy identity", slog.String("path", c.Request.URL.Path), log.Error(err))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, schema.ErrorResponse("login required"))
+			return
+		}
+	} else {
+		if err == nil {
+			st = state.Get(s)
+		}
+		if err != nil || !authenticated(c.Request, st) {
+			slog.Debug("auth: unauthenticated api call", slog.String("path", c.Request.URL.Path))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, schema.ErrorResponse("login required"))
+			return
+		}
+	}
+	c.Set(state.SessionKey, s)
+	c.Set(state.StateKey, st)
+	c.Next()
+}
+
+func anonymous(c *gin.Context, s string, err error) {
 	if err != nil {
 		slog.Error("auth", log.Error(err))
 		slog.Info("generate session for new user")
@@ -23,27 +59,107 @@ func Auth(c *gin.Context) {
 		c.Abort()
 		return
 	}
-	if state.Get(s) == nil {
-		state.Add(s)
+	st := state.Get(s)
+	if st == nil {
+		if st, err = state.Add(s); err != nil {
+			slog.Error("auth: add session", log.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, schema.ErrorResponse(err.Error()))
+			return
+		}
 	}
 	c.Set(state.SessionKey, s)
+	c.Set(state.StateKey, st)
 	c.Next()
 }
 
-func K8s(next http.Handler) http.Handler {
+func authenticated(r *http.Request, st *models.State) bool {
+	if st == nil {
+		return false
+	}
+	if conf.AuthEnabled(conf.AuthNone) {
+		return true
+	}
+	if st.Identity == nil {
+		return false
+	}
+	if conf.AuthEnabled(conf.AuthProxy) {
+		// the session is the user's as long as the proxy says so
+		id, err := proxyIdentity(r)
+		return err == nil && id.User == st.Identity.User
+	}
+	return true
+}
+
+// Session returns the state of the authenticated session of r, or nil.
+func Session(r *http.Request) *models.State {
+	s, err := r.Cookie(state.SessionKey)
+	if err != nil {
+		return nil
+	}
+	st := state.Get(s.Value)
+	if !authenticated(r, st) {
+		return nil
+	}
+	return st
+}
+
+// Login starts a session for id, a new one so that a session id set before
+// the login can't be reused.
+func Login(c *gin.Context, id *models.Identity) error {
+	_, _, err := newSession(c.Writer, c.Request, &models.State{Identity: id})
+	return err
+}
+
+// LoginAccount is Login for the local account of id, the session ends when the account is removed.
+func LoginAccount(c *gin.Context, id *models.Identity) error {
+	_, _, err := newSession(c.Writer, c.Request, &models.State{Identity: id, Account: true})
+	return err
+}
+
+func newSession(w http.ResponseWriter, r *http.Request, st *models.State) (string, *models.State, error) {
+	if c, err := r.Cookie(state.SessionKey); err == nil {
+		state.Remove(c.Value)
+	}
+	s := uuid.NewString()
+	if err := state.Save(s, st); err != nil {
+		return "", nil, err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     state.SessionKey,
+		Value:    s,
+		MaxAge:   state.SessionMinutes * 60,
+		Path:     "/",
+		HttpOnly: true,
+	})
+	slog.Info("login", slog.String("user", st.Identity.User))
+	return s, st, nil
+}
+
+// Logout ends the session of c.
+func Logout(c *gin.Context) {
+	if s, err := c.Cookie(state.SessionKey); err == nil {
+		state.Remove(s)
+	}
+	c.SetCookie(state.SessionKey, "", -1, "/", "", false, true)
+}
+
+// Page redirects the page requests of unauthenticated sessions to the login page, which goes back to them.
+// With AUTH=proxy, there is no login page and requests without the identity headers are rejected.
+func Page(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s, err := r.Cookie(state.SessionKey)
-		if err != nil {
-			slog.Error("auth: get session for new user", log.Error(err))
-			util.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		if conf.AuthEnabled(conf.AuthNone) || Session(r) != nil {
+			next.ServeHTTP(w, r)
 			return
 		}
-		st := state.Get(s.Value)
-		if st == nil || st.Namespace == "" || st.Pod == "" || st.Container == "" {
-			slog.Error("auth", slog.String("error", "namespace, pod or container is required"))
-			util.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		if conf.AuthEnabled(conf.AuthProxy) {
+			if _, _, err := proxySession(w, r); err != nil {
+				slog.Warn("auth: proxy identity", slog.String("path", r.URL.Path), log.Error(err))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
 			return
 		}
-		next.ServeHTTP(w, r)
+		util.Redirect(w, r, loginLink(r), http.StatusTemporaryRedirect)
 	})
 }
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/zrcoder/amisgo/util"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+const (
+	// FilesPage lists a directory of the container in its query, see FilesLink.
+	FilesPage = "/files"
+	// NextParam is the query parameter of the login page with the page to go back to.
+	NextParam = "next"
+)
+
+// HomeLink returns the home page with the cluster, namespace and pod of t selected, as far as they are set,
+// passing on whether the files pages show the dotfiles.
+func HomeLink(t models.Target, hidden bool) string {
+	return "/" + query("cluster", t.Cluster, "namespace", t.Namespace, "pod", t.Pod, "hidden", strconv.FormatBool(hidden))
+}
+
+// FilesLink returns the files page listing the directory dir of the container t, with the dotfiles if hidden.
+func FilesLink(t models.Target, dir string, hidden bool) string {
+	return FilesPage + query("cluster", t.Cluster, "namespace", t.Namespace, "pod", t.Pod, "container", t.Container, "path", dir,
+		"hidden", strconv.FormatBool(hidden))
+}
+
+// query returns the url query of the names and values in pairs, in their order and without the empty values.
+func query(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(pairs[i] + "=" + url.QueryEscape(pairs[i+1]))
+	}
+	return sb.String()
+}
+
+// LocalPath reports whether next is a page of podFiles rather than another site,
+// so that it is safe to go to after a login.
+func LocalPath(next string) bool {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.Contains(next, `\`) {
+		return false
+	}
+	u, err := url.Parse(next)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// loginLink returns the login page going back to the page of r.
+func loginLink(r *http.Request) string {
+	if r.URL.Path == "/" && r.URL.RawQuery == "" {
+		return LoginPage
+	}
+	return LoginPage + query(NextParam, r.URL.RequestURI())
+}
+
+// SafeNext drops the page to go back to from the query of the login page unless it is a LocalPath.
+func SafeNext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if n := q.Get(NextParam); n != "" && !LocalPath(n) {
+			slog.Warn("auth: login back to another site", slog.String(NextParam, n))
+			q.Del(NextParam)
+			r.URL.RawQuery = q.Encode()
+			util.Redirect(w, r, r.URL.RequestURI(), http.StatusTemporaryRedirect)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// K8s is Page for the pages of a container, which address it in their query.
+// Without one, they go to the container of the session if any, home otherwise.
+func K8s(next http.Handler) http.Handler {
+	return Page(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("namespace") != "" && q.Get("pod") != "" && q.Get("container") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s, err := r.Cookie(state.SessionKey); err == nil {
+			if st := state.Get(s.Value); st != nil && st.Namespace != "" && st.Pod != "" && st.Container != "" {
+				util.Redirect(w, r, FilesLink(st.Target(), st.FSPath(), st.ShowHidden), http.StatusTemporaryRedirect)
+				return
+			}
+		}
+		slog.Warn("auth", slog.String("error", "namespace, pod or container is required"))
+		util.Redirect(w, r, HomeLink(models.Target{Cluster: q.Get("cluster"), Namespace: q.Get("namespace"), Pod: q.Get("pod")}, q.Get("hidden") == "true"), http.StatusTemporaryRedirect)
+	}))
+}
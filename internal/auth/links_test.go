@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		next string
+		want bool
+	}{
+		{next: "/", want: true},
+		{next: "/files?namespace=a&pod=b&container=c&path=%2Fvar%2Flog", want: true},
+		{next: ""},
+		{next: "files"},
+		{next: "//evil.example"},
+		{next: `/\evil.example`},
+		{next: "https://evil.example/"},
+		{next: "/%zz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.next, func(t *testing.T) {
+			if got := LocalPath(tt.next); got != tt.want {
+				t.Errorf("LocalPath(%q) = %v, want %v", tt.next, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	target := models.Target{Namespace: "default", Pod: "web-0", Container: "nginx"}
+	if got, want := FilesLink(target, "/var/log", true), "/files?namespace=default&pod=web-0&container=nginx&path=%2Fvar%2Flog&hidden=true"; got != want {
+		t.Errorf("FilesLink() = %q, want %q", got, want)
+	}
+	if got, want := HomeLink(models.Target{Cluster: "prod", Namespace: "a b"}, false), "/?cluster=prod&namespace=a+b&hidden=false"; got != want {
+		t.Errorf("HomeLink() = %q, want %q", got, want)
+	}
+	if got, want := HomeLink(models.Target{}, true), "/?hidden=true"; got != want {
+		t.Errorf("HomeLink() = %q, want %q", got, want)
+	}
+}
+
+func TestK8s(t *testing.T) {
+	t.Setenv("AUTH", conf.AuthNone)
+	st, err := state.Add("k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.SetNamespace("default")
+	st.SetPod("web-0")
+	st.SetContainer("nginx")
+	st.AddPath("etc")
+	st.SetShowHidden(true)
+	if err := state.Save("k8s", st); err != nil {
+		t.Fatal(err)
+	}
+	h := K8s(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name     string
+		url      string
+		session  bool
+		location string
+	}{
+		{name: "addressed", url: "/files?namespace=default&pod=web-0&container=nginx"},
+		{name: "session", url: "/files", session: true, location: "/files?namespace=default&pod=web-0&container=nginx&path=%2Fetc&hidden=true"},
+		{name: "partial", url: "/files?namespace=default&pod=web-0", location: "/?namespace=default&pod=web-0&hidden=false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.session {
+				r.AddCookie(&http.Cookie{Name: state.SessionKey, Value: "k8s"})
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("K8s() redirected to %q, want %q", got, tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+var errUntrustedProxy = errors.New("request not from a trusted proxy")
+
+// proxyIdentity returns the identity in the headers of r, set by an authenticating
+// reverse proxy. The headers are only read from the trusted proxies, as anyone else may set them.
+func proxyIdentity(r *http.Request) (*models.Identity, error) {
+	trusted, err := trustedProxy(r.RemoteAddr, conf.ProxyTrustedCIDRs())
+	if err != nil {
+		return nil, err
+	}
+	if !trusted {
+		return nil, fmt.Errorf("%w: %s", errUntrustedProxy, r.RemoteAddr)
+	}
+	userHeader, groupsHeader := conf.ProxyHeaders()
+	user := strings.TrimSpace(r.Header.Get(userHeader))
+	if user == "" {
+		return nil, fmt.Errorf("missing %s header", userHeader)
+	}
+	var groups []string
+	for _, g := range strings.Split(r.Header.Get(groupsHeader), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return &models.Identity{User: user, Groups: groups}, nil
+}
+
+// trustedProxy reports whether the peer at addr is in one of cidrs.
+// It is the peer address, X-Forwarded-For is set by the client as well.
+func trustedProxy(addr string, cidrs []string) (bool, error) {
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		return false, err
+	}
+	ip := ap.Addr().Unmap()
+	for _, c := range cidrs {
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			return false, fmt.Errorf("trusted proxy cidr: %w", err)
+		}
+		if prefix.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// proxySession returns the session of the user in the proxy headers of r and its state,
+// starting a new one when the user changes.
+func proxySession(w http.ResponseWriter, r *http.Request) (string, *models.State, error) {
+	id, err := proxyIdentity(r)
+	if err != nil {
+		return "", nil, err
+	}
+	if c, err := r.Cookie(state.SessionKey); err == nil {
+		if st := state.Get(c.Value); st != nil && st.Identity != nil && st.Identity.User == id.User {
+			// the groups may change meanwhile
+			if !slices.Equal(st.Identity.Groups, id.Groups) {
+				st.Identity = id
+				if err := state.Save(c.Value, st); err != nil {
+					return "", nil, err
+				}
+			}
+			return c.Value, st, nil
+		}
+	}
+	return newSession(w, r, &models.State{Identity: id})
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+func TestTrustedProxy(t *testing.T) {
+	cidrs := []string{"10.0.0.0/8", "fd00::/8"}
+	tests := []struct {
+		addr    string
+		want    bool
+		wantErr bool
+	}{
+		{addr: "10.1.2.3:4567", want: true},
+		{addr: "[::ffff:10.1.2.3]:4567", want: true},
+		{addr: "[fd00::1]:80", want: true},
+		{addr: "192.168.1.1:4567"},
+		{addr: "10.1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got, err := trustedProxy(tt.addr, cidrs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("trustedProxy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("trustedProxy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := trustedProxy("10.1.2.3:80", []string{"10.0.0.0"}); err == nil {
+		t.Error("trustedProxy() accepted a malformed cidr")
+	}
+}
+
+func TestAuthProxy(t *testing.T) {
+	t.Setenv("AUTH", "proxy")
+	t.Setenv("PROXY_TRUSTED_CIDRS", "10.0.0.0/8")
+	t.Setenv("PROXY_USER_HEADER", "X-Auth-Request-User")
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/", Auth, func(c *gin.Context) {
+		c.String(http.StatusOK, state.Get(c.GetString(state.SessionKey)).Identity.User)
+	})
+	call := func(remote string, header http.Header, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	alice := http.Header{"X-Auth-Request-User": {"alice"}, "X-Forwarded-Groups": {"qa, sre"}}
+
+	tests := []struct {
+		name   string
+		remote string
+		header http.Header
+		want   int
+	}{
+		{name: "trusted proxy", remote: "10.0.0.1:1234", header: alice, want: http.StatusOK},
+		{name: "untrusted peer", remote: "192.168.0.1:1234", header: alice, want: http.StatusUnauthorized},
+		{name: "no user header", remote: "10.0.0.1:1234", header: http.Header{"X-Forwarded-User": {"alice"}}, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := call(tt.remote, tt.header, nil); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	// the session follows the user of the proxy
+	w := call("10.0.0.1:1234", alice, nil)
+	cookie := w.Result().Cookies()[0]
+	st := state.Get(cookie.Value)
+	if st == nil || !slices.Equal(st.Identity.Groups, []string{"qa", "sre"}) {
+		t.Fatalf("session state = %+v, want alice in qa and sre", st)
+	}
+	if w := call("10.0.0.1:1234", alice, cookie); len(w.Result().Cookies()) != 0 || w.Body.String() != "alice" {
+		t.Errorf("same user got a new session or %q", w.Body.String())
+	}
+	w = call("10.0.0.1:1234", http.Header{"X-Auth-Request-User": {"bob"}}, cookie)
+	if cookies := w.Result().Cookies(); len(cookies) == 0 || cookies[0].Value == cookie.Value || w.Body.String() != "bob" {
+		t.Errorf("other user kept the session of alice")
+	}
+	if state.Get(cookie.Value) != nil {
+		t.Error("session of alice kept after another user")
+	}
+	if w := call("192.168.0.1:1234", alice, cookie); w.Code != http.StatusUnauthorized {
+		t.Errorf("session without the proxy headers: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
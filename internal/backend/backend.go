@@ -0,0 +1,133 @@
+// Package backend defines what the api handlers need from the containers they browse,
+// so that the transport can be swapped: exec in a cluster, a local directory, a fake...
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+// FileBufferSize defines the standard buffer size used for I/O operations
+const FileBufferSize = 32 * 1024 // 32KB
+
+// Navigator lists the containers that can be browsed.
+type Navigator interface {
+	// Clusters lists the clusters that can be browsed.
+	Clusters(ctx context.Context) ([]models.Cluster, error)
+	// Namespaces lists the namespaces of t.Cluster not in the blacklist.
+	Namespaces(ctx context.Context, t models.Target) ([]models.Namespace, error)
+	// Pods lists the running pods in t.Namespace.
+	Pods(ctx context.Context, t models.Target) ([]models.Pod, error)
+	// Containers lists the containers of t.Pod.
+	Containers(ctx context.Context, t models.Target) ([]models.Container, error)
+	// Pod describes t.Pod as seen from t.Container.
+	Pod(ctx context.Context, t models.Target) (*models.PodInfo, error)
+}
+
+// FileSystem accesses the files of the container t.
+// Paths are absolute and already validated by the caller.
+type FileSystem interface {
+	// List lists the entries of dir, including the hidden ones.
+	List(ctx context.Context, t models.Target, dir string) ([]models.FileInfo, error)
+	// Stat describes the file at p, symlinks are not followed.
+	Stat(ctx context.Context, t models.Target, p string) (*models.FileInfo, error)
+	// RealPath returns p with all its symlinks resolved, so that the rules apply to the file it really is.
+	// It fails with fs.ErrNotExist when a parent of p doesn't exist.
+	RealPath(ctx context.Context, t models.Target, p string) (string, error)
+	// Read writes the content of the regular file at p to w.
+	Read(ctx context.Context, t models.Target, p string, w io.Writer) error
+	// ReadRange writes at most limit bytes of the regular file at p from offset to w.
+	ReadRange(ctx context.Context, t models.Target, p string, offset, limit int64, w io.Writer) error
+	// Tail writes the last lines of the file at p to w, then the lines appended to it until ctx is done,
+	// following it when it is rotated or truncated as tail -F does.
+	Tail(ctx context.Context, t models.Target, p string, lines int, w io.Writer) error
+	// Archive writes a gzipped tarball of the file or directory at p to w.
+	Archive(ctx context.Context, t models.Target, p string, w io.Writer) error
+	// Write creates or replaces the file at p with size bytes read from r.
+	Write(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode) error
+	// The changes below fail with ErrReadOnly, fs.ErrNotExist, fs.ErrExist, fs.ErrPermission or ErrUnknownID when they apply.
+
+	// Replace replaces the regular file at p with size bytes read from r, the mode and the owner uid:gid, atomically:
+	// the content is written to a temporary file in the same directory, which is renamed to p.
+	// p must not be a symlink, the rename would replace the link rather than the file it links to.
+	Replace(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode, uid, gid int) error
+	// Remove removes the file at p, directories need recursive.
+	Remove(ctx context.Context, t models.Target, p string, recursive bool) error
+	// Rename renames or moves from to to, it fails with fs.ErrExist if to exists.
+	Rename(ctx context.Context, t models.Target, from, to string) error
+	// Mkdir creates the directory p, its parent must exist.
+	Mkdir(ctx context.Context, t models.Target, p string) error
+	// Chmod sets the permissions of p to mode, and of everything below the directory p with recursive.
+	Chmod(ctx context.Context, t models.Target, p string, mode fs.FileMode, recursive bool) error
+	// Chown sets the owner and the group of p, names or numeric ids, either may be empty to keep it.
+	// With recursive, it sets those of everything below the directory p as well.
+	Chown(ctx context.Context, t models.Target, p, owner, group string, recursive bool) error
+	// Capabilities reports the tools available to access the container.
+	Capabilities(ctx context.Context, t models.Target) (*models.Capabilities, error)
+}
+
+// Backend is everything the api handlers need.
+type Backend interface {
+	Navigator
+	FileSystem
+}
+
+// TokenReviewer authenticates the users by their bearer tokens.
+type TokenReviewer interface {
+	// ReviewToken returns the identity the token authenticates, or ErrInvalidToken.
+	ReviewToken(ctx context.Context, token string) (*models.Identity, error)
+}
+
+// ErrInvalidToken reports a bearer token that authenticates no one.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// ErrNoIdentity reports a call without an authenticated user where one is required,
+// such as when the cluster calls impersonate the users.
+var ErrNoIdentity = errors.New("an authenticated user is required")
+
+// ErrReadOnly reports a change to a read-only filesystem, such as a read-only root or volume.
+var ErrReadOnly = errors.New("the filesystem is read-only")
+
+// ErrIsDir reports an operation on a directory that needs a file, such as removing it without recursive.
+var ErrIsDir = errors.New("is a directory")
+
+// ErrUnknownID reports a user or group unknown to the container.
+var ErrUnknownID = errors.New("no such user or group")
+
+// ToolMissingError reports that the container lacks the tools an operation needs.
+type ToolMissingError struct {
+	Tools []string
+	Err   error
+}
+
+func (e *ToolMissingError) Error() string {
+	msg := "the container lacks " + strings.Join(e.Tools, ", ")
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ToolMissingError) Unwrap() error {
+	return e.Err
+}
+
+// FilterHidden drops "." and "..", and dotfiles unless showHidden.
+func FilterHidden(files []models.FileInfo, showHidden bool) []models.FileInfo {
+	res := files[:0]
+	for _, f := range files {
+		if f.Name == "." || f.Name == ".." {
+			continue
+		}
+		if !showHidden && strings.HasPrefix(f.Name, ".") {
+			continue
+		}
+		res = append(res, f)
+	}
+	return res
+}
@@ -0,0 +1,34 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+func TestFilterHidden(t *testing.T) {
+	files := func(names ...string) []models.FileInfo {
+		res := []models.FileInfo{}
+		for _, name := range names {
+			res = append(res, models.FileInfo{Name: name})
+		}
+		return res
+	}
+	tests := []struct {
+		name       string
+		showHidden bool
+		want       []models.FileInfo
+	}{
+		{name: "hide", showHidden: false, want: files("app", "a.env")},
+		{name: "show", showHidden: true, want: files(".env", "app", ".ssh", "a.env")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterHidden(files(".", "..", ".env", "app", ".ssh", "a.env"), tt.showHidden)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterHidden() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+// Package local serves a directory of the local filesystem as a single container,
+// it is handy to develop and test podFiles without a cluster.
+// Symlinks inside the directory are followed, do not serve untrusted trees.
+package local
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+// Name is the name of the only cluster, namespace, pod and container.
+const Name = "local"
+
+// modeBits are the bits of a mode which chmod sets.
+const modeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// FS is a backend.Backend rooted at a local directory.
+type FS struct {
+	root string
+}
+
+var _ backend.Backend = (*FS)(nil)
+
+func New(root string) (*FS, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	// RealPath compares the resolved paths with it
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	return &FS{root: root}, nil
+}
+
+func (l *FS) Clusters(ctx context.Context) ([]models.Cluster, error) {
+	return []models.Cluster{{Cluster: Name}}, nil
+}
+
+func (l *FS) Namespaces(ctx context.Context, t models.Target) ([]models.Namespace, error) {
+	return []models.Namespace{{Namespace: Name}}, nil
+}
+
+func (l *FS) Pods(ctx context.Context, t models.Target) ([]models.Pod, error) {
+	return []models.Pod{{Pod: Name}}, nil
+}
+
+func (l *FS) Containers(ctx context.Context, t models.Target) ([]models.Container, error) {
+	return []models.Container{{Container: Name}}, nil
+}
+
+func (l *FS) Pod(ctx context.Context, t models.Target) (*models.PodInfo, error) {
+	return &models.PodInfo{}, nil
+}
+
+// path maps the container path p into the root.
+func (l *FS) path(p string) string {
+	return filepath.Join(l.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+func (l *FS) List(ctx context.Context, t models.Target, dir string) ([]models.FileInfo, error) {
+	entries, err := os.ReadDir(l.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]models.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := l.Stat(ctx, t, path.Join(dir, e.Name()))
+		if err != nil {
+			// removed meanwhile
+			continue
+		}
+		files = append(files, *info)
+	}
+	return files, nil
+}
+
+func (l *FS) Stat(ctx context.Context, t models.Target, p string) (*models.FileInfo, error) {
+	full := l.path(p)
+	fi, err := os.Lstat(full)
+	if err != nil {
+		return nil, err
+	}
+	info := &models.FileInfo{
+		Name: fi.Name(),
+		Type: models.FileType(fi.Mode()),
+		Size: fi.Size(),
+		Time: fi.ModTime().Unix(),
+		Mode: fi.Mode(),
+	}
+	info.UID, info.GID, info.Owner, info.Group = owner(fi)
+	if info.Type == models.FileTypeLink {
+		info.LinkTarget, _ = os.Readlink(full)
+		if target, err := os.Stat(full); err == nil {
+			info.LinkDir = target.IsDir()
+		}
+	}
+	return info, nil
+}
+
+// RealPath resolves the symlinks of p on the host. A path leading out of the root
+// is returned as the host path it is, so that the rules deny the same files there.
+func (l *FS) RealPath(ctx context.Context, t models.Target, p string) (string, error) {
+	real, err := filepath.EvalSymlinks(l.path(p))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(l.root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(real), nil
+	}
+	return path.Clean("/" + filepath.ToSlash(rel)), nil
+}
+
+func (l *FS) Read(ctx context.Context, t models.Target, p string, w io.Writer) error {
+	f, err := os.Open(l.path(p))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (l *FS) ReadRange(ctx context.Context, t models.Target, p string, offset, limit int64, w io.Writer) error {
+	f, err := os.Open(l.path(p))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, io.NewSectionReader(f, offset, limit))
+	return err
+}
+
+// tailPoll is how often Tail looks for new lines.
+const tailPoll = 250 * time.Millisecond
+
+func (l *FS) Tail(ctx context.Context, t models.Target, p string, lines int, w io.Writer) error {
+	full := l.path(p)
+	f, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+	offset, err := lastLines(f, lines)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(tailPoll)
+	defer ticker.Stop()
+	for {
+		n, err := io.Copy(w, f)
+		if err != nil {
+			return err
+		}
+		offset += n
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		// start over a truncated file, or the new file of the name once rotated
+		cur, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		named, err := os.Stat(full)
+		if err != nil {
+			// being rotated, try again
+			continue
+		}
+		if !os.SameFile(cur, named) {
+			nf, err := os.Open(full)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f, offset = nf, 0
+		} else if named.Size() < offset {
+			if offset, err = f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// lastLines returns the offset of the last lines of f.
+func lastLines(f *os.File, lines int) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	end := fi.Size()
+	if lines <= 0 {
+		return end, nil
+	}
+	buf := make([]byte, backend.FileBufferSize)
+	found := 0
+	for pos := end; pos > 0; {
+		n := int64(len(buf))
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return 0, err
+		}
+		for i := n - 1; i >= 0; i-- {
+			// a newline ending the file ends its last line rather than starting another
+			if buf[i] != '\n' || pos+i == end-1 {
+				continue
+			}
+			if found++; found == lines {
+				return pos + i + 1, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+func (l *FS) Archive(ctx context.Context, t models.Target, p string, w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	full := l.path(p)
+	parent := filepath.Dir(full)
+	err := filepath.WalkDir(full, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(parent, file)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (l *FS) Write(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode) error {
+	f, err := os.OpenFile(l.path(p), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return readOnly(err)
+	}
+	_, err = io.CopyN(f, r, size)
+	return errors.Join(err, f.Close())
+}
+
+func (l *FS) Replace(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode, uid, gid int) error {
+	full := l.path(p)
+	f, err := os.CreateTemp(filepath.Dir(full), "."+filepath.Base(full)+".podfiles-*")
+	if err != nil {
+		return readOnly(err)
+	}
+	_, err = io.CopyN(f, r, size)
+	err = errors.Join(err, f.Close())
+	if err == nil {
+		// before the mode, chown drops the setuid and setgid bits
+		err = keepOwner(f.Name(), p, uid, gid)
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode&modeBits)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), full)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return readOnly(err)
+	}
+	return nil
+}
+
+// chownFile is chown, the tests replace it to act as a user who isn't root.
+var chownFile = chown
+
+// keepOwner gives the temporary file name the owner uid and the group gid of the file p it replaces.
+// It has them already when users who aren't root edit their own files, who may not chown then.
+func keepOwner(name, p string, uid, gid int) error {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if fileUID, fileGID, _, _ := owner(fi); fileUID == uid && fileGID == gid {
+		return nil
+	}
+	if err := chownFile(name, uid, gid); err != nil {
+		return fmt.Errorf("keep the owner %d:%d of %s: %w", uid, gid, p, err)
+	}
+	return nil
+}
+
+func (l *FS) Remove(ctx context.Context, t models.Target, p string, recursive bool) error {
+	if recursive {
+		return readOnly(os.RemoveAll(l.path(p)))
+	}
+	return readOnly(os.Remove(l.path(p)))
+}
+
+func (l *FS) Rename(ctx context.Context, t models.Target, from, to string) error {
+	return readOnly(rename(l.path(from), l.path(to)))
+}
+
+// lstatRename is rename where the system can't refuse to replace to, it checks first whether to exists.
+func lstatRename(from, to string) error {
+	if _, err := os.Lstat(to); err == nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (l *FS) Mkdir(ctx context.Context, t models.Target, p string) error {
+	return readOnly(os.Mkdir(l.path(p), 0o755))
+}
+
+func (l *FS) Chmod(ctx context.Context, t models.Target, p string, mode fs.FileMode, recursive bool) error {
+	return readOnly(walk(ctx, l.path(p), recursive, func(file string, d fs.DirEntry) error {
+		// chmod follows symlinks, leave them alike chmod -R
+		if d != nil && d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		return os.Chmod(file, mode&modeBits)
+	}))
+}
+
+func (l *FS) Chown(ctx context.Context, t models.Target, p, owner, group string, recursive bool) error {
+	uid, gid := -1, -1
+	var err error
+	if owner != "" {
+		if uid, err = lookupID(owner, user.Lookup, func(u *user.User) string { return u.Uid }); err != nil {
+			return err
+		}
+	}
+	if group != "" {
+		if gid, err = lookupID(group, user.LookupGroup, func(g *user.Group) string { return g.Gid }); err != nil {
+			return err
+		}
+	}
+	return readOnly(walk(ctx, l.path(p), recursive, func(file string, d fs.DirEntry) error {
+		return os.Lchown(file, uid, gid)
+	}))
+}
+
+// walk calls fn on root, and on everything below it with recursive.
+func walk(ctx context.Context, root string, recursive bool, fn func(file string, d fs.DirEntry) error) error {
+	if !recursive {
+		return fn(root, nil)
+	}
+	return filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(file, d)
+	})
+}
+
+// lookupID returns the numeric id, or the id of the user or group named name.
+func lookupID[T any](name string, lookup func(string) (T, error), id func(T) string) (int, error) {
+	if n, err := strconv.Atoi(name); err == nil {
+		return n, nil
+	}
+	v, err := lookup(name)
+	if err != nil {
+		var unknownUser user.UnknownUserError
+		var unknownGroup user.UnknownGroupError
+		if errors.As(err, &unknownUser) || errors.As(err, &unknownGroup) {
+			return 0, fmt.Errorf("%w: %w", backend.ErrUnknownID, err)
+		}
+		return 0, err
+	}
+	return strconv.Atoi(id(v))
+}
+
+// readOnly wraps backend.ErrReadOnly around the errors of read-only filesystems.
+func readOnly(err error) error {
+	if errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("%w: %w", backend.ErrReadOnly, err)
+	}
+	return err
+}
+
+func (l *FS) Capabilities(ctx context.Context, t models.Target) (*models.Capabilities, error) {
+	return &models.Capabilities{Tools: []string{}, Missing: []string{}, Unsupported: []string{}}, nil
+}
@@ -0,0 +1,262 @@
+package local
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	target := models.Target{Namespace: Name, Pod: Name, Container: Name}
+	root := t.TempDir()
+	l, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Mkdir(ctx, target, "/etc"); err != nil {
+		t.Fatal(err)
+	}
+	content := "key: value\n"
+	if err := l.Write(ctx, target, "/etc/app.yaml", strings.NewReader(content), int64(len(content)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("etc", filepath.Join(root, "config")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := l.List(ctx, target, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	if len(files) != 2 || files[0].Name != "config" || !files[0].LinkDir || files[0].LinkTarget != "etc" ||
+		files[1].Name != "etc" || files[1].Type != models.FileTypeDir {
+		t.Fatalf("List() = %+v", files)
+	}
+
+	info, err := l.Stat(ctx, target, "/etc/app.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != models.FileTypeFile || info.Size != int64(len(content)) || info.Mode != 0o600 {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	if real, err := l.RealPath(ctx, target, "/config/app.yaml"); err != nil || real != "/etc/app.yaml" {
+		t.Errorf("RealPath() = %q, %v, want /etc/app.yaml", real, err)
+	}
+	if _, err := l.RealPath(ctx, target, "/config/missing/app.yaml"); !os.IsNotExist(err) {
+		t.Errorf("RealPath() of a missing parent error = %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := l.Read(ctx, target, "/etc/app.yaml", buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != content {
+		t.Errorf("Read() = %q, want %q", buf, content)
+	}
+
+	buf.Reset()
+	if err := l.Archive(ctx, target, "/etc", buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if strings.Join(names, ",") != "etc,etc/app.yaml" {
+		t.Errorf("Archive() has %v", names)
+	}
+
+	edited := "key: other\n"
+	if err := l.Replace(ctx, target, "/etc/app.yaml", strings.NewReader(edited), int64(len(edited)), 0o640, os.Getuid(), os.Getgid()); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "etc", "app.yaml")); err != nil || string(b) != edited {
+		t.Errorf("content after Replace() = %q, %v", b, err)
+	}
+	if files, err := l.List(ctx, target, "/etc"); err != nil || len(files) != 1 || files[0].Mode != 0o640 {
+		t.Errorf("List() after Replace() = %+v, %v, want the file alone with its mode", files, err)
+	}
+	// users who aren't root edit their own files, and may not give them away
+	chownFile = func(name string, uid, gid int) error {
+		return &fs.PathError{Op: "chown", Path: name, Err: syscall.EPERM}
+	}
+	if err := l.Replace(ctx, target, "/etc/app.yaml", strings.NewReader(edited), int64(len(edited)), 0o640, os.Getuid(), os.Getgid()); err != nil {
+		t.Errorf("Replace() of an own file without chown = %v", err)
+	}
+	err = l.Replace(ctx, target, "/etc/app.yaml", strings.NewReader("key: lost\n"), int64(len("key: lost\n")), 0o640, os.Getuid()+1, os.Getgid())
+	if !errors.Is(err, fs.ErrPermission) || !strings.Contains(err.Error(), "owner") {
+		t.Errorf("Replace() of a file of another user without chown = %v, want a permission error about the owner", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(root, "etc", "app.yaml")); err != nil || string(b) != edited {
+		t.Errorf("content after a failed Replace() = %q, %v", b, err)
+	}
+	if files, err := l.List(ctx, target, "/etc"); err != nil || len(files) != 1 {
+		t.Errorf("List() after a failed Replace() = %+v, %v, want no temporary file", files, err)
+	}
+	chownFile = chown
+	// only root gives files away
+	if os.Getuid() == 0 {
+		if err := l.Replace(ctx, target, "/etc/app.yaml", strings.NewReader(edited), int64(len(edited)), 0o640, 1234, 1234); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := l.Stat(ctx, target, "/etc/app.yaml"); err != nil || info.UID != 1234 || info.GID != 1234 {
+			t.Errorf("Stat() after Replace() = %+v, %v, want the owner 1234:1234", info, err)
+		}
+	}
+
+	if err := l.Chmod(ctx, target, "/etc", 0o750, true); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := l.Stat(ctx, target, "/etc/app.yaml"); err != nil || info.Mode != 0o750 {
+		t.Errorf("Stat() after Chmod() = %+v, %v", info, err)
+	}
+	if err := l.Chown(ctx, target, "/etc", strconv.Itoa(os.Getuid()), "", true); err != nil {
+		t.Error(err)
+	}
+	if err := l.Chown(ctx, target, "/etc", "no-such-user-podfiles", "", false); !errors.Is(err, backend.ErrUnknownID) {
+		t.Errorf("Chown() of an unknown user error = %v", err)
+	}
+
+	if err := l.Rename(ctx, target, "/etc/app.yaml", "/etc/app.yml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Remove(ctx, target, "/etc", false); err == nil {
+		t.Error("Remove() of a non empty directory succeeded without recursive")
+	}
+	if err := l.Remove(ctx, target, "/etc", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Stat(ctx, target, "/etc"); !os.IsNotExist(err) {
+		t.Errorf("Stat() after Remove() error = %v", err)
+	}
+
+	// paths can not escape the root
+	if got := l.path("/../../etc/passwd"); got != filepath.Join(root, "etc", "passwd") {
+		t.Errorf("path() = %v", got)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		content string
+		lines   int
+		want    int64
+	}{
+		{content: "a\nb\nc\n", lines: 2, want: 2},
+		{content: "a\nb\nc", lines: 2, want: 2},
+		{content: "a\nb\nc\n", lines: 5, want: 0},
+		{content: "a\nb\nc\n", lines: 0, want: 6},
+		{content: "", lines: 3, want: 0},
+		{content: strings.Repeat("x", 40000) + "\nlast\n", lines: 1, want: 40001},
+	}
+	for _, tt := range tests {
+		f, err := os.CreateTemp(t.TempDir(), "log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(tt.content); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := lastLines(f, tt.lines); err != nil || got != tt.want {
+			t.Errorf("lastLines(%.10q, %d) = %d, %v, want %d", tt.content, tt.lines, got, err, tt.want)
+		}
+		f.Close()
+	}
+}
+
+// syncBuffer is a buffer written by Tail while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// waitFor waits for the buffer to hold want.
+func (b *syncBuffer) waitFor(t *testing.T, want string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		got := b.buf.String()
+		b.mu.Unlock()
+		if got == want {
+			return
+		}
+	}
+	t.Fatalf("Tail() wrote %q, want %q", b.buf.String(), want)
+}
+
+func TestTail(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "app.log")
+	if err := os.WriteFile(file, []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	buf := new(syncBuffer)
+	done := make(chan error)
+	go func() {
+		done <- l.Tail(ctx, models.Target{}, "/app.log", 2, buf)
+	}()
+	buf.waitFor(t, "b\nc\n")
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("d\n")
+	f.Close()
+	buf.waitFor(t, "b\nc\nd\n")
+
+	// truncated, as by copytruncate
+	if err := os.WriteFile(file, []byte("e\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buf.waitFor(t, "b\nc\nd\ne\n")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Tail() = %v after the cancel", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package local
+
+import "io/fs"
+
+// owner returns nothing, the files have no unix owner here.
+func owner(fi fs.FileInfo) (uid, gid int, owner, group string) {
+	return 0, 0, "", ""
+}
+
+// chown does nothing, the files have no unix owner here.
+func chown(name string, uid, gid int) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package local
+
+import (
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// owner returns the ids of the owner and the group of fi, with their names if they have some.
+func owner(fi fs.FileInfo) (uid, gid int, owner, group string) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, "", ""
+	}
+	uid, gid = int(st.Uid), int(st.Gid)
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		owner = u.Username
+	}
+	if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+		group = g.Name
+	}
+	return uid, gid, owner, group
+}
+
+// chown sets the owner and the group of the file name.
+func chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package local
+
+// rename renames from to to, it fails with fs.ErrExist rather than replace to.
+func rename(from, to string) error {
+	return lstatRename(from, to)
+}
@@ -1,58 +1,82 @@
 package k8s
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
+	"net/http"
+	"path"
 	"strings"
+	"time"
 
+	"github.com/patrickmn/go-cache"
 	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/backend"
 	"github.com/zrcoder/podFiles/internal/models"
-	"github.com/zrcoder/podFiles/internal/state"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
 )
 
-// FileBufferSize defines the standard buffer size used for I/O operations
-const FileBufferSize = 32 * 1024 // 32KB
-
-// FileTypeMap maps file type indicators from ls output to readable types
-var fileTypeMap = map[string]string{
-	"d": "dir",
-	"-": "file",
-}
+const (
+	// podTTL is how long a pod is reused, so that the calls of a request,
+	// such as the path rules, the operation and its event, get it once
+	podTTL = 5 * time.Second
+	// probeTTL is how long the probed tools and the debug containers of a pod are kept
+	probeTTL = time.Hour
+)
 
-// Client represents a Kubernetes client
+// Client represents a Kubernetes client of a cluster,
+// accessing files by exec in the containers.
 type Client struct {
 	clientset *kubernetes.Clientset
 	config    *rest.Config
+	// httpClient is shared by the clients impersonating the users
+	httpClient *http.Client
+
+	// pods caches the pods for podTTL, by user and pod
+	pods *cache.Cache
+	// toolsets caches the probed tools for probeTTL, by pod UID and container
+	toolsets *cache.Cache
+	// debugContainers maps the containers without the tools to their debug container for probeTTL,
+	// by pod UID and container
+	debugContainers *cache.Cache
 }
 
-func New() (*Client, error) {
-	// if conf.KubeConfigPath() is empty, will call inClusterConfig inside BuildConfigFromFlags
-	config, err := clientcmd.BuildConfigFromFlags("", conf.KubeConfigPath())
+func newClient(config *rest.Config) (*Client, error) {
+	httpClient, err := rest.HTTPClientFor(config)
 	if err != nil {
 		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	clientset, err := kubernetes.NewForConfigAndClient(config, httpClient)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{clientset: clientset, config: config}, nil
+	return &Client{
+		clientset:       clientset,
+		config:          config,
+		httpClient:      httpClient,
+		pods:            cache.New(podTTL, time.Minute),
+		toolsets:        cache.New(probeTTL, 10*time.Minute),
+		debugContainers: cache.New(probeTTL, 10*time.Minute),
+	}, nil
 }
 
-func (c *Client) ListCommonNamespaces(ctx context.Context) ([]models.Namespace, error) {
-	list, err := c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+func (c *Client) Namespaces(ctx context.Context, t models.Target) ([]models.Namespace, error) {
+	uc, err := c.as(t)
+	if err != nil {
+		return nil, err
+	}
+	list, err := uc.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -69,14 +93,17 @@ func (c *Client) ListCommonNamespaces(ctx context.Context) ([]models.Namespace,
 	return ns, nil
 }
 
-func (c *Client) ListRunningPods(ctx context.Context, session string) ([]models.Pod, error) {
-	st := state.Get(session)
-	if st.Namespace == "" {
+func (c *Client) Pods(ctx context.Context, t models.Target) ([]models.Pod, error) {
+	if t.Namespace == "" {
 		msg := "namespace is required"
 		slog.Error(msg)
 		return nil, errors.New(msg)
 	}
-	list, err := c.clientset.CoreV1().Pods(st.Namespace).List(ctx, metav1.ListOptions{})
+	uc, err := c.as(t)
+	if err != nil {
+		return nil, err
+	}
+	list, err := uc.clientset.CoreV1().Pods(t.Namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -93,14 +120,13 @@ func (c *Client) ListRunningPods(ctx context.Context, session string) ([]models.
 	return names, nil
 }
 
-func (c *Client) ListContainers(ctx context.Context, session string) ([]models.Container, error) {
-	st := state.Get(session)
-	if st.Namespace == "" || st.Pod == "" {
+func (c *Client) Containers(ctx context.Context, t models.Target) ([]models.Container, error) {
+	if t.Namespace == "" || t.Pod == "" {
 		msg := "namespace and pod are required"
 		slog.Error(msg)
 		return nil, errors.New(msg)
 	}
-	p, err := c.clientset.CoreV1().Pods(st.Namespace).Get(ctx, st.Pod, metav1.GetOptions{})
+	p, err := c.getPod(ctx, t)
 	if err != nil {
 		return nil, err
 	}
@@ -114,159 +140,156 @@ func (c *Client) ListContainers(ctx context.Context, session string) ([]models.C
 	return containers, nil
 }
 
-func (c *Client) ListFiles(ctx context.Context, st *models.State) ([]models.FileInfo, error) {
-	if st.Namespace == "" || st.Pod == "" || st.Container == "" {
-		msg := "namespace, pod or container is required"
-		slog.Info(msg)
-		return nil, errors.New(msg)
+// Pod returns the labels of t.Pod and the mount paths of its secrets in t.Container:
+// secret volumes, and projected volumes with secrets or service account tokens.
+func (c *Client) Pod(ctx context.Context, t models.Target) (*models.PodInfo, error) {
+	p, err := c.getPod(ctx, t)
+	if err != nil {
+		return nil, err
 	}
+	return &models.PodInfo{Labels: p.Labels, SecretMounts: secretMounts(p, t.Container)}, nil
+}
 
-	dir := st.FSPath()
-	if dir == "" {
-		dir = "/"
+func secretMounts(p *corev1.Pod, container string) []string {
+	secrets := map[string]bool{}
+	for _, v := range p.Spec.Volumes {
+		switch {
+		case v.Secret != nil:
+			secrets[v.Name] = true
+		case v.Projected != nil:
+			for _, s := range v.Projected.Sources {
+				if s.Secret != nil || s.ServiceAccountToken != nil {
+					secrets[v.Name] = true
+				}
+			}
+		}
 	}
-
-	slog.Debug("list files", "namespace", st.Namespace, "pod", st.Pod, "container", st.Container, "dir", dir)
-
-	cmd := []string{"ls", "-lh", dir}
-	req := c.clientset.CoreV1().RESTClient().Post().
-		Resource("pods").Name(st.Pod).Namespace(st.Namespace).SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: st.Container,
-			Command:   cmd,
-			Stdout:    true,
-			Stderr:    true,
-		}, scheme.ParameterCodec)
-
-	executor, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
-	if err != nil {
-		return nil, err
+	mounts := []string{}
+	for _, ctr := range p.Spec.Containers {
+		if ctr.Name != container {
+			continue
+		}
+		for _, m := range ctr.VolumeMounts {
+			if secrets[m.Name] {
+				mounts = append(mounts, path.Join(m.MountPath, m.SubPath))
+			}
+		}
 	}
+	return mounts
+}
 
+// output runs cmd in the container and returns its stdout, once it exits with 0.
+func (c *Client) output(ctx context.Context, t models.Target, cmd []string) (string, error) {
 	output := bytes.NewBuffer(nil)
 	outputErr := bytes.NewBuffer(nil)
-	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdout: output,
-		Stderr: outputErr,
-	})
+	err := c.exec(ctx, t, cmd, nil, output, outputErr)
 	if err != nil {
-		return nil, fmt.Errorf("exec error: %w, output: %s", err, output.String())
+		if fsErr := fsError(outputErr.String()); fsErr != nil {
+			err = fmt.Errorf("%w: %w", fsErr, err)
+		}
+		return "", fmt.Errorf("exec error: %w, output: %s", err, outputErr.String())
 	}
 	if outputErr.Len() > 0 {
-		return nil, fmt.Errorf("exec error: %s", outputErr.String())
+		// the command succeeded, such as find past an entry removed meanwhile
+		slog.Debug("exec stderr", slog.Any("cmd", cmd), slog.String("stderr", outputErr.String()))
 	}
-
-	return parseFileList(output.String()), nil
+	return output.String(), nil
 }
 
-// parseFileList parses the output of the `ls -lF` command and returns a slice of FileInfo structs.
-func parseFileList(output string) []models.FileInfo {
-	files := []models.FileInfo{}
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		// Skip empty lines and lines starting with "total"
-		if line == "" || strings.HasPrefix(line, "total") {
-			continue
-		}
-		fields := strings.Fields(line)
-		// Skip lines with insufficient fields
-		if len(fields) < 9 {
-			continue
-		}
-		fileType, ok := fileTypeMap[fields[0][0:1]]
-		if !ok {
-			continue
-		}
-		name := strings.Join(fields[8:], " ")
-		file := models.FileInfo{
-			Name: name,
-			Type: fileType,
-			Size: fields[4],
-			Time: fields[5] + "-" + fields[6] + " " + fields[7],
-		}
-		files = append(files, file)
+// run runs cmd in the container, discarding its stdout.
+func (c *Client) run(ctx context.Context, t models.Target, cmd []string) error {
+	errBuf := new(bytes.Buffer)
+	if err := c.exec(ctx, t, cmd, nil, io.Discard, errBuf); err != nil {
+		return execError(err, errBuf)
 	}
-	return files
+	return nil
 }
 
-func (c *Client) DownloadFile(ctx context.Context, session, filePath string, writer io.Writer) error {
-	st := state.Get(session)
-	if st.Namespace == "" || st.Pod == "" || st.Container == "" {
+// exec runs cmd in the container, streaming the given stdio.
+func (c *Client) exec(ctx context.Context, t models.Target, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if t.Namespace == "" || t.Pod == "" || t.Container == "" {
 		return errors.New("namespace, pod or container is required")
 	}
 
-	// Use shell command to execute tar
-	shellCmd := fmt.Sprintf("cd %s && tar czf - %s", st.FSPath(), filePath)
-	cmd := []string{"/bin/sh", "-c", shellCmd}
-
-	req := c.clientset.CoreV1().RESTClient().Post().
-		Resource("pods").Name(st.Pod).Namespace(st.Namespace).SubResource("exec").
-		Param("container", st.Container).
+	uc, err := c.as(t)
+	if err != nil {
+		return err
+	}
+	req := uc.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Name(t.Pod).Namespace(t.Namespace).SubResource("exec").
 		VersionedParams(&corev1.PodExecOptions{
-			Command: cmd,
-			Stdout:  true,
-			Stderr:  true,
+			Container: t.Container,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
 		}, scheme.ParameterCodec)
 
-	executor, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
+	executor, err := remotecommand.NewSPDYExecutor(uc.config, "POST", req.URL())
 	if err != nil {
 		return fmt.Errorf("failed to create executor: %w", err)
 	}
 
-	// Create a buffer for stderr
-	errBuf := new(bytes.Buffer)
-
-	// Stream directly to the writer without buffering the entire content in memory
 	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdout: writer,
-		Stderr: errBuf,
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
 	})
-	if err != nil {
-		errMsg := err.Error()
-		if errBuf.Len() > 0 {
-			errMsg = fmt.Sprintf("%v: %s", err, errBuf.String())
-		}
-		return fmt.Errorf("exec error: %v", errMsg)
+	if err != nil && toolMissing(err) {
+		return &backend.ToolMissingError{Tools: []string{cmd[0]}, Err: err}
 	}
-
-	return nil
+	return err
 }
 
-func (c *Client) UploadFile(ctx context.Context, namespace, pod, container, targetDir string, reader io.Reader) error {
-	// Use buffered reader to control memory usage
-	bufReader := bufio.NewReaderSize(reader, FileBufferSize)
-
-	cmd := []string{"tar", "xf", "-", "-C", targetDir}
-	req := c.clientset.CoreV1().RESTClient().Post().
-		Resource("pods").Name(pod).Namespace(namespace).SubResource("exec").
-		Param("container", container).
-		VersionedParams(&corev1.PodExecOptions{
-			Command: cmd,
-			Stdin:   true,
-			Stdout:  true,
-			Stderr:  true,
-		}, scheme.ParameterCodec)
-
-	executor, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
-	if err != nil {
-		return err
+func execError(err error, stderr *bytes.Buffer) error {
+	if stderr.Len() > 0 {
+		if fsErr := fsError(stderr.String()); fsErr != nil {
+			err = fmt.Errorf("%w: %w", fsErr, err)
+		}
+		return fmt.Errorf("exec error: %w: %s", err, stderr.String())
 	}
+	return fmt.Errorf("exec error: %w", err)
+}
 
-	errBuf := new(bytes.Buffer)
+// fsErrors are the messages of the coreutils and busybox tools for the errors of backend.FileSystem.
+var fsErrors = []struct {
+	msg string
+	err error
+}{
+	{"Read-only file system", backend.ErrReadOnly},
+	{"No such file or directory", fs.ErrNotExist},
+	{"File exists", fs.ErrExist},
+	{"Directory not empty", fs.ErrExist},
+	{"not replacing", fs.ErrExist},
+	// coreutils and busybox
+	{"Is a directory", backend.ErrIsDir},
+	{"is a directory", backend.ErrIsDir},
+	{"Permission denied", fs.ErrPermission},
+	{"Operation not permitted", fs.ErrPermission},
+	{"invalid user", backend.ErrUnknownID},
+	{"invalid group", backend.ErrUnknownID},
+	{"unknown user", backend.ErrUnknownID},
+	{"unknown group", backend.ErrUnknownID},
+}
 
-	// Stream data directly from reader to pod without buffering entire content
-	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdin:  bufReader,
-		Stdout: io.Discard, // Discard stdout
-		Stderr: errBuf,
-	})
-	if err != nil {
-		errMsg := err.Error()
-		if errBuf.Len() > 0 {
-			errMsg = fmt.Sprintf("%v: %s", err, errBuf.String())
+// fsError returns the error reported by the stderr of a failed command, or nil.
+func fsError(stderr string) error {
+	for _, e := range fsErrors {
+		if strings.Contains(stderr, e.msg) {
+			return e.err
 		}
-		return fmt.Errorf("exec error: %v", errMsg)
 	}
-
 	return nil
 }
+
+// toolMissing reports whether the exec error means the command could not be started.
+func toolMissing(err error) bool {
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		// as reported by shells and some runtimes
+		return exitErr.ExitStatus() == 126 || exitErr.ExitStatus() == 127
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "executable file not found") ||
+		strings.Contains(msg, `exec: "`) && strings.Contains(msg, "no such file or directory")
+}
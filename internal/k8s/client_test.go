@@ -0,0 +1,161 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func TestToolMissing(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "runtime",
+			err:  errors.New(`OCI runtime exec failed: exec failed: unable to start container process: exec: "ls": executable file not found in $PATH: unknown`),
+			want: true,
+		},
+		{
+			name: "absolute path",
+			err:  errors.New(`exec failed: unable to start container process: exec: "/bin/sh": stat /bin/sh: no such file or directory: unknown`),
+			want: true,
+		},
+		{
+			name: "shell",
+			err:  utilexec.CodeExitError{Err: errors.New("command terminated with exit code 127"), Code: 127},
+			want: true,
+		},
+		{
+			name: "command failed",
+			err:  fmt.Errorf("exec error: %w", utilexec.CodeExitError{Err: errors.New("command terminated with exit code 2"), Code: 2}),
+			want: false,
+		},
+		{
+			name: "missing file",
+			err:  errors.New("command terminated with exit code 1: ls: /nope: No such file or directory"),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toolMissing(tt.err); got != tt.want {
+				t.Errorf("toolMissing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretMounts(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Volumes: []corev1.Volume{
+			{Name: "certs", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "certs"}}},
+			{Name: "kube-api-access", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token"}}},
+			}}},
+			{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{}}},
+		},
+		Containers: []corev1.Container{
+			{Name: "app", VolumeMounts: []corev1.VolumeMount{
+				{Name: "certs", MountPath: "/etc/tls", SubPath: "tls.key"},
+				{Name: "kube-api-access", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount"},
+				{Name: "config", MountPath: "/etc/app"},
+			}},
+			{Name: "sidecar", VolumeMounts: []corev1.VolumeMount{{Name: "certs", MountPath: "/certs"}}},
+		},
+	}}
+	want := []string{"/etc/tls/tls.key", "/var/run/secrets/kubernetes.io/serviceaccount"}
+	if got := secretMounts(pod, "app"); !slices.Equal(got, want) {
+		t.Errorf("secretMounts() = %q, want %q", got, want)
+	}
+}
+
+func TestFSError(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{stderr: "mkdir: can't create directory '/etc/app': Read-only file system", want: backend.ErrReadOnly},
+		{stderr: "rm: cannot remove '/etc/app.conf': Read-only file system", want: backend.ErrReadOnly},
+		{stderr: "mv: can't rename '/a': No such file or directory", want: fs.ErrNotExist},
+		{stderr: "mkdir: cannot create directory '/tmp/a': File exists", want: fs.ErrExist},
+		{stderr: "rm: cannot remove '/tmp': Is a directory", want: backend.ErrIsDir},
+		{stderr: "rm: '/tmp' is a directory", want: backend.ErrIsDir},
+		{stderr: "rmdir: failed to remove '/tmp': Directory not empty", want: fs.ErrExist},
+		{stderr: "rmdir: '/tmp': Directory not empty", want: fs.ErrExist},
+		{stderr: "mv: not replacing '/tmp/b'", want: fs.ErrExist},
+		{stderr: "rm: cannot remove '/root/a': Permission denied", want: fs.ErrPermission},
+		{stderr: "chown: changing ownership of '/etc/hosts': Operation not permitted", want: fs.ErrPermission},
+		{stderr: "chown: invalid user: 'nobody2:www'", want: backend.ErrUnknownID},
+		{stderr: "chown: unknown group www", want: backend.ErrUnknownID},
+		{stderr: "mv: cannot move '/a' to '/a/b': Invalid argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stderr, func(t *testing.T) {
+			if got := fsError(tt.stderr); got != tt.want {
+				t.Errorf("fsError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	err := execError(errors.New("command terminated with exit code 1"), bytes.NewBufferString("rm: cannot remove '/a': Read-only file system"))
+	if !errors.Is(err, backend.ErrReadOnly) {
+		t.Errorf("execError() = %v, want a backend.ErrReadOnly", err)
+	}
+}
+
+func TestGetPod(t *testing.T) {
+	var gets atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web-0","namespace":"default","uid":"1234"}}`))
+	}))
+	defer srv.Close()
+	c, err := newClient(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(id *models.Identity) {
+		t.Helper()
+		pod, err := c.getPod(context.Background(), models.Target{Namespace: "default", Pod: "web-0", Identity: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pod.UID != "1234" {
+			t.Errorf("getPod() = %+v", pod)
+		}
+	}
+	alice, bob := &models.Identity{User: "alice"}, &models.Identity{User: "bob"}
+
+	// the calls of a request share the pod, whoever the user is without impersonation
+	get(alice)
+	get(alice)
+	get(bob)
+	if n := gets.Load(); n != 1 {
+		t.Errorf("%d gets of the pod, want 1", n)
+	}
+
+	// impersonated users get it apart
+	t.Setenv("IMPERSONATE", "true")
+	get(alice)
+	get(bob)
+	get(bob)
+	if n := gets.Load(); n != 3 {
+		t.Errorf("%d gets of the pod, want 3", n)
+	}
+}
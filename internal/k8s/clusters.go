@@ -0,0 +1,267 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/util/log"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Clusters is a backend.Backend routing each call to the client of its target's cluster.
+type Clusters struct {
+	names   []string
+	clients map[string]*Client
+	// def serves the targets without a cluster
+	def string
+}
+
+var (
+	_ backend.Backend       = (*Clusters)(nil)
+	_ backend.TokenReviewer = (*Clusters)(nil)
+)
+
+// New returns the clusters of the contexts in the kubeconfig files listed in KUBECONFIG,
+// or the cluster podFiles runs in without KUBECONFIG.
+func New() (*Clusters, error) {
+	configs, def, err := loadConfigs(conf.KubeConfigPath(), conf.KubeContexts())
+	if err != nil {
+		return nil, err
+	}
+	cs := &Clusters{clients: map[string]*Client{}, def: def}
+	for name, config := range configs {
+		client, err := newClient(config)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", name, err)
+		}
+		cs.names = append(cs.names, name)
+		cs.clients[name] = client
+	}
+	slices.Sort(cs.names)
+	return cs, nil
+}
+
+// loadConfigs returns the configs of the contexts in the kubeconfig files of paths,
+// restricted to only if not empty, and the name of the default one.
+func loadConfigs(paths string, only []string) (map[string]*rest.Config, string, error) {
+	if paths == "" {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, "", err
+		}
+		return map[string]*rest.Config{conf.ClusterName(): config}, conf.ClusterName(), nil
+	}
+
+	rules := &clientcmd.ClientConfigLoadingRules{Precedence: filepath.SplitList(paths)}
+	raw, err := rules.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	configs := map[string]*rest.Config{}
+	for name := range raw.Contexts {
+		if len(only) > 0 && !slices.Contains(only, name) {
+			continue
+		}
+		config, err := clientcmd.NewNonInteractiveClientConfig(*raw, name, &clientcmd.ConfigOverrides{}, rules).ClientConfig()
+		if err != nil {
+			slog.Warn("skip kubeconfig context", slog.String("context", name), log.Error(err))
+			continue
+		}
+		configs[name] = config
+	}
+	if len(configs) == 0 {
+		return nil, "", fmt.Errorf("no usable context in %s", paths)
+	}
+
+	def := raw.CurrentContext
+	if _, ok := configs[def]; !ok {
+		names := make([]string, 0, len(configs))
+		for name := range configs {
+			names = append(names, name)
+		}
+		def = slices.Min(names)
+	}
+	return configs, def, nil
+}
+
+func (cs *Clusters) client(t models.Target) (*Client, error) {
+	name := t.Cluster
+	if name == "" {
+		name = cs.def
+	}
+	c, ok := cs.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %q", t.Cluster)
+	}
+	return c, nil
+}
+
+func (cs *Clusters) Clusters(ctx context.Context) ([]models.Cluster, error) {
+	clusters := make([]models.Cluster, 0, len(cs.names))
+	for _, name := range cs.names {
+		clusters = append(clusters, models.Cluster{Cluster: name})
+	}
+	return clusters, nil
+}
+
+func (cs *Clusters) Namespaces(ctx context.Context, t models.Target) ([]models.Namespace, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.Namespaces(ctx, t)
+}
+
+func (cs *Clusters) Pods(ctx context.Context, t models.Target) ([]models.Pod, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.Pods(ctx, t)
+}
+
+func (cs *Clusters) Containers(ctx context.Context, t models.Target) ([]models.Container, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.Containers(ctx, t)
+}
+
+func (cs *Clusters) Pod(ctx context.Context, t models.Target) (*models.PodInfo, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.Pod(ctx, t)
+}
+
+func (cs *Clusters) List(ctx context.Context, t models.Target, dir string) ([]models.FileInfo, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.List(ctx, t, dir)
+}
+
+func (cs *Clusters) Stat(ctx context.Context, t models.Target, p string) (*models.FileInfo, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.Stat(ctx, t, p)
+}
+
+func (cs *Clusters) RealPath(ctx context.Context, t models.Target, p string) (string, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return "", err
+	}
+	return c.RealPath(ctx, t, p)
+}
+
+func (cs *Clusters) Read(ctx context.Context, t models.Target, p string, w io.Writer) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Read(ctx, t, p, w)
+}
+
+func (cs *Clusters) Archive(ctx context.Context, t models.Target, p string, w io.Writer) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Archive(ctx, t, p, w)
+}
+
+func (cs *Clusters) Write(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Write(ctx, t, p, r, size, mode)
+}
+
+func (cs *Clusters) ReadRange(ctx context.Context, t models.Target, p string, offset, limit int64, w io.Writer) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.ReadRange(ctx, t, p, offset, limit, w)
+}
+
+func (cs *Clusters) Tail(ctx context.Context, t models.Target, p string, lines int, w io.Writer) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Tail(ctx, t, p, lines, w)
+}
+
+func (cs *Clusters) Replace(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode, uid, gid int) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Replace(ctx, t, p, r, size, mode, uid, gid)
+}
+
+func (cs *Clusters) Remove(ctx context.Context, t models.Target, p string, recursive bool) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Remove(ctx, t, p, recursive)
+}
+
+func (cs *Clusters) Rename(ctx context.Context, t models.Target, from, to string) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Rename(ctx, t, from, to)
+}
+
+func (cs *Clusters) Mkdir(ctx context.Context, t models.Target, p string) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Mkdir(ctx, t, p)
+}
+
+func (cs *Clusters) Chmod(ctx context.Context, t models.Target, p string, mode fs.FileMode, recursive bool) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Chmod(ctx, t, p, mode, recursive)
+}
+
+func (cs *Clusters) Chown(ctx context.Context, t models.Target, p, owner, group string, recursive bool) error {
+	c, err := cs.client(t)
+	if err != nil {
+		return err
+	}
+	return c.Chown(ctx, t, p, owner, group, recursive)
+}
+
+func (cs *Clusters) Capabilities(ctx context.Context, t models.Target) (*models.Capabilities, error) {
+	c, err := cs.client(t)
+	if err != nil {
+		return nil, err
+	}
+	return c.Capabilities(ctx, t)
+}
@@ -0,0 +1,76 @@
+package k8s
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+current-context: %s
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[1]s.example.com
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+users:
+- name: %[1]s
+  user:
+    token: secret
+`
+
+func writeKubeConfig(t *testing.T, name string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	content := []byte(fmt.Sprintf(testKubeConfig, name))
+	if err := os.WriteFile(p, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadConfigs(t *testing.T) {
+	dev, prod := writeKubeConfig(t, "dev"), writeKubeConfig(t, "prod")
+	paths := dev + string(filepath.ListSeparator) + prod
+
+	tests := []struct {
+		name     string
+		only     []string
+		clusters []string
+		def      string
+	}{
+		{name: "all", clusters: []string{"dev", "prod"}, def: "dev"},
+		{name: "only", only: []string{"prod"}, clusters: []string{"prod"}, def: "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, def, err := loadConfigs(paths, tt.only)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := slices.Sorted(maps.Keys(configs)); !slices.Equal(got, tt.clusters) {
+				t.Errorf("clusters = %q, want %q", got, tt.clusters)
+			}
+			if def != tt.def {
+				t.Errorf("default = %q, want %q", def, tt.def)
+			}
+			for name, config := range configs {
+				if want := "https://" + name + ".example.com"; config.Host != want {
+					t.Errorf("host of %s = %q, want %q", name, config.Host, want)
+				}
+			}
+		})
+	}
+
+	if _, _, err := loadConfigs(paths, []string{"nope"}); err == nil {
+		t.Error("loadConfigs() without a matching context succeeded")
+	}
+}
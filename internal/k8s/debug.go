@@ -0,0 +1,128 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	debugContainerPrefix = "podfiles-debug-"
+	// debugRoot is where a debug container sees the filesystem of its target,
+	// as it shares the process namespace of the target container.
+	debugRoot = "/proc/1/root"
+
+	debugStartTimeout = 2 * time.Minute
+)
+
+// conn is where the commands for a container run: the container itself,
+// or an ephemeral debug container seeing its filesystem under root.
+type conn struct {
+	models.Target
+	root string
+}
+
+// path maps the path p of the target container into the conn.
+func (cn conn) path(p string) string {
+	return cn.root + p
+}
+
+func targetKey(t models.Target) string {
+	return t.Namespace + "/" + t.Pod + "/" + t.Container
+}
+
+// debugContainer returns the debug container targeting t, starting it once per pod UID and container.
+func (c *Client) debugContainer(ctx context.Context, t models.Target, pod *corev1.Pod) (string, error) {
+	key := string(pod.UID) + "/" + t.Container
+	if name, ok := c.debugContainers.Get(key); ok {
+		return name.(string), nil
+	}
+
+	slog.Info("tools missing in container, use a debug container", slog.String("container", targetKey(t)))
+	name, err := c.ensureDebugContainer(ctx, t, pod)
+	if err != nil {
+		return "", err
+	}
+	c.debugContainers.SetDefault(key, name)
+	return name, nil
+}
+
+func debugConn(t models.Target, name string) conn {
+	t.Container = name
+	return conn{Target: t, root: debugRoot}
+}
+
+func debugContainerName(container string) string {
+	name := debugContainerPrefix + container
+	// container names are DNS labels
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// ensureDebugContainer returns the running debug container targeting t,
+// adding it to the pod if needed.
+func (c *Client) ensureDebugContainer(ctx context.Context, t models.Target, pod *corev1.Pod) (string, error) {
+	name := debugContainerName(t.Container)
+	uc, err := c.as(t)
+	if err != nil {
+		return "", err
+	}
+	pods := uc.clientset.CoreV1().Pods(t.Namespace)
+
+	exists := false
+	for _, ec := range pod.Spec.EphemeralContainers {
+		if ec.Name == name {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		slog.Info("add debug container", slog.String("container", targetKey(t)), slog.String("image", conf.DebugImage()))
+		// the pod is shared by getPod
+		pod = pod.DeepCopy()
+		pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, corev1.EphemeralContainer{
+			TargetContainerName: t.Container,
+			EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+				Name:            name,
+				Image:           conf.DebugImage(),
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				// keep it alive for the following requests, ephemeral containers can't be removed
+				Command: []string{"sleep", "2147483647"},
+			},
+		})
+		if _, err := pods.UpdateEphemeralContainers(ctx, t.Pod, pod, metav1.UpdateOptions{}); err != nil {
+			return "", err
+		}
+	}
+
+	err = wait.PollUntilContextTimeout(ctx, time.Second, debugStartTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := pods.Get(ctx, t.Pod, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, s := range pod.Status.EphemeralContainerStatuses {
+			if s.Name != name {
+				continue
+			}
+			if s.State.Terminated != nil {
+				return false, fmt.Errorf("debug container %s terminated: %s", name, s.State.Terminated.Reason)
+			}
+			return s.State.Running != nil, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
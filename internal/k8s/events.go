@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/util/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of the events recorded on the pods whose files change
+const (
+	ReasonUpload = "PodFilesUpload"
+	ReasonEdit   = "PodFilesEdit"
+	ReasonRemove = "PodFilesRemove"
+	ReasonRename = "PodFilesRename"
+	ReasonMkdir  = "PodFilesMkdir"
+	ReasonChmod  = "PodFilesChmod"
+	ReasonChown  = "PodFilesChown"
+
+	eventComponent = "podfiles"
+)
+
+// event records a change of the files of t on its pod, as shown by kubectl describe pod.
+// The event is created by podFiles itself, the users need no rights on events.
+// Failing to record it doesn't fail the change.
+func (c *Client) event(ctx context.Context, t models.Target, reason, format string, args ...any) {
+	if !conf.PodEvents() {
+		return
+	}
+	pod, err := c.getPod(ctx, t)
+	if err == nil {
+		_, err = c.clientset.CoreV1().Events(t.Namespace).Create(ctx, newEvent(pod, t, reason, fmt.Sprintf(format, args...), time.Now()), metav1.CreateOptions{})
+	}
+	if err != nil {
+		slog.Warn("record pod event", slog.String("reason", reason), slog.String("pod", t.Pod), log.Error(err))
+	}
+}
+
+func newEvent(pod *corev1.Pod, t models.Target, reason, message string, now time.Time) *corev1.Event {
+	user := "anonymous"
+	if t.Identity != nil {
+		user = t.Identity.User
+	}
+	host, _ := os.Hostname()
+	ts := metav1.NewTime(now)
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pod.Name + ".",
+			Namespace:    pod.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "Pod",
+			Namespace:       pod.Namespace,
+			Name:            pod.Name,
+			UID:             pod.UID,
+			ResourceVersion: pod.ResourceVersion,
+			FieldPath:       fmt.Sprintf("spec.containers{%s}", t.Container),
+		},
+		Reason:              reason,
+		Message:             fmt.Sprintf("%s %s via podFiles", user, message),
+		Type:                corev1.EventTypeNormal,
+		Source:              corev1.EventSource{Component: eventComponent},
+		FirstTimestamp:      ts,
+		LastTimestamp:       ts,
+		Count:               1,
+		ReportingController: eventComponent,
+		ReportingInstance:   host,
+	}
+}
@@ -0,0 +1,39 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/models"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewEvent(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "prod", UID: "uid-1"}}
+	tests := []struct {
+		name        string
+		identity    *models.Identity
+		wantMessage string
+	}{
+		{name: "user", identity: &models.Identity{User: "alice"}, wantMessage: "alice uploaded /tmp/a (3 bytes) via podFiles"},
+		{name: "anonymous", wantMessage: "anonymous uploaded /tmp/a (3 bytes) via podFiles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := models.Target{Namespace: "prod", Pod: "web-1", Container: "app", Identity: tt.identity}
+			e := newEvent(pod, target, ReasonUpload, "uploaded /tmp/a (3 bytes)", time.Now())
+			if e.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", e.Message, tt.wantMessage)
+			}
+			ref := e.InvolvedObject
+			if ref.Kind != "Pod" || ref.Name != "web-1" || ref.Namespace != "prod" || ref.UID != "uid-1" || ref.FieldPath != "spec.containers{app}" {
+				t.Errorf("involved object = %+v, want container app of pod prod/web-1", ref)
+			}
+			if e.Namespace != "prod" || e.Reason != ReasonUpload || e.Type != corev1.EventTypeNormal {
+				t.Errorf("event = %s/%s %s %s", e.Namespace, e.GenerateName, e.Type, e.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+func (c *Client) List(ctx context.Context, t models.Target, dir string) ([]models.FileInfo, error) {
+	// a trailing slash makes symlinked directories list their content
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	slog.Debug("list files", "namespace", t.Namespace, "pod", t.Pod, "container", t.Container, "dir", dir)
+
+	cn, s, err := c.connFor(ctx, t, listStrategies)
+	if err != nil {
+		return nil, err
+	}
+	return c.list(ctx, cn, s, cn.path(dir))
+}
+
+func (c *Client) list(ctx context.Context, cn conn, s *strategy, dir string) ([]models.FileInfo, error) {
+	if s == listLs {
+		output, err := c.output(ctx, cn.Target, lsCmd(dir))
+		if err != nil {
+			return nil, err
+		}
+		return parseFileList(output, time.Now()), nil
+	}
+
+	output, err := c.output(ctx, cn.Target, listCmd(dir))
+	if err != nil {
+		// some find and stat builds lack the flags we use
+		slog.Debug("list files with stat, fall back to ls", log.Error(err))
+		return c.list(ctx, cn, listLs, dir)
+	}
+	files := parseStatList(output)
+	for _, f := range files {
+		if f.Type == models.FileTypeLink {
+			c.resolveLinks(ctx, cn, dir, files)
+			break
+		}
+	}
+	return files, nil
+}
+
+// resolveLinks marks the symlinks in files that point to directories.
+// It is best effort: dangling links make stat fail for themselves only.
+// In a debug container, absolute links resolve against its own root.
+func (c *Client) resolveLinks(ctx context.Context, cn conn, dir string, files []models.FileInfo) {
+	output := bytes.NewBuffer(nil)
+	outputErr := bytes.NewBuffer(nil)
+	err := c.exec(ctx, cn.Target, linkDirsCmd(dir), nil, output, outputErr)
+	if err != nil {
+		slog.Debug("resolve links", log.Error(err), slog.String("stderr", outputErr.String()))
+	}
+	dirs := parseLinkDirs(output.String())
+	for i := range files {
+		if files[i].Type == models.FileTypeLink && dirs[files[i].Name] {
+			files[i].LinkDir = true
+		}
+	}
+}
+
+func (c *Client) Stat(ctx context.Context, t models.Target, p string) (*models.FileInfo, error) {
+	cn, s, err := c.connFor(ctx, t, statStrategies)
+	if err != nil {
+		return nil, err
+	}
+	var files []models.FileInfo
+	if s == statStat {
+		output, err := c.output(ctx, cn.Target, statCmd(cn.path(p)))
+		if err == nil {
+			files = parseStatList(output)
+		} else {
+			slog.Debug("stat file, fall back to ls", log.Error(err))
+			s = listLs
+		}
+	}
+	if s == listLs {
+		output, err := c.output(ctx, cn.Target, lsStatCmd(cn.path(p)))
+		if err != nil {
+			return nil, err
+		}
+		files = parseFileList(output, time.Now())
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("stat %s: unexpected output", p)
+	}
+	files[0].Name = path.Base(p)
+	return &files[0], nil
+}
+
+// RealPath resolves the symlinks of p with readlink.
+// In a debug container, absolute links resolve against its own root, as in resolveLinks.
+func (c *Client) RealPath(ctx context.Context, t models.Target, p string) (string, error) {
+	cn, _, err := c.connFor(ctx, t, realPathStrategies)
+	if err != nil {
+		return "", err
+	}
+	output, err := c.output(ctx, cn.Target, realPathCmd(cn.path(p)))
+	if err != nil {
+		var missing *backend.ToolMissingError
+		if errors.As(err, &missing) || errors.Is(err, fs.ErrPermission) {
+			return "", err
+		}
+		// readlink -f reports nothing about the missing parents
+		return "", fmt.Errorf("%w: %w", fs.ErrNotExist, err)
+	}
+	real := strings.TrimSuffix(output, "\n")
+	if !path.IsAbs(real) {
+		return "", fmt.Errorf("readlink %s: unexpected output %q", p, output)
+	}
+	if cn.root != "" {
+		if rel, ok := strings.CutPrefix(real, cn.root); ok && (rel == "" || strings.HasPrefix(rel, "/")) {
+			real = "/" + strings.TrimPrefix(rel, "/")
+		}
+	}
+	return real, nil
+}
+
+func (c *Client) Read(ctx context.Context, t models.Target, p string, w io.Writer) error {
+	cn, s, err := c.connFor(ctx, t, readStrategies)
+	if err != nil {
+		return err
+	}
+	return c.read(ctx, cn, s, cn.path(p), w)
+}
+
+// read writes the content of file to w, with cat or base64.
+func (c *Client) read(ctx context.Context, cn conn, s *strategy, file string, w io.Writer) error {
+	errBuf := new(bytes.Buffer)
+	if s == readCat {
+		if err := c.exec(ctx, cn.Target, catCmd(file), nil, w, errBuf); err != nil {
+			return execError(err, errBuf)
+		}
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := c.exec(ctx, cn.Target, base64Cmd(file), nil, pw, errBuf)
+		if err != nil {
+			err = execError(err, errBuf)
+		}
+		pw.CloseWithError(err)
+	}()
+	defer pr.Close()
+	// the decoder skips the line breaks of base64
+	_, err := io.Copy(w, base64.NewDecoder(base64.StdEncoding, pr))
+	return err
+}
+
+func (c *Client) ReadRange(ctx context.Context, t models.Target, p string, offset, limit int64, w io.Writer) error {
+	cn, s, err := c.connFor(ctx, t, rangeStrategies)
+	if err != nil {
+		return err
+	}
+	if s == readTailHead {
+		errBuf := new(bytes.Buffer)
+		err := c.exec(ctx, cn.Target, rangeCmd(cn.path(p), offset, limit), nil, w, errBuf)
+		if err == nil && errBuf.Len() > 0 {
+			// the pipeline exits as head, tail may have failed
+			err = errors.New("tail failed")
+		}
+		if err != nil {
+			return execError(err, errBuf)
+		}
+		return nil
+	}
+
+	// stop reading once the range is written
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rw := &rangeWriter{w: w, skip: offset, left: limit}
+	err = c.read(ctx, cn, s, cn.path(p), rw)
+	if rw.left == 0 {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) Tail(ctx context.Context, t models.Target, p string, lines int, w io.Writer) error {
+	cn, _, err := c.connFor(ctx, t, tailStrategies)
+	if err != nil {
+		return err
+	}
+	// the exec lasts until ctx is done, which closes its stream
+	errBuf := new(bytes.Buffer)
+	err = c.exec(ctx, cn.Target, tailCmd(cn.path(p), lines), nil, w, errBuf)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err == nil {
+		// tail -F exits by itself only when it can't follow the file
+		err = errors.New("tail exited")
+	}
+	return execError(err, errBuf)
+}
+
+// rangeWriter writes to w the left bytes after the skip first ones, failing with errRangeDone after them.
+type rangeWriter struct {
+	w          io.Writer
+	skip, left int64
+}
+
+var errRangeDone = errors.New("range written")
+
+func (rw *rangeWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	if rw.skip >= int64(len(b)) {
+		rw.skip -= int64(len(b))
+		return n, nil
+	}
+	b = b[rw.skip:]
+	rw.skip = 0
+	if int64(len(b)) > rw.left {
+		b = b[:rw.left]
+	}
+	if _, err := rw.w.Write(b); err != nil {
+		return 0, err
+	}
+	rw.left -= int64(len(b))
+	if rw.left == 0 {
+		return n, errRangeDone
+	}
+	return n, nil
+}
+
+func (c *Client) Archive(ctx context.Context, t models.Target, p string, w io.Writer) error {
+	info, err := c.Stat(ctx, t, p)
+	if err != nil {
+		return err
+	}
+	strategies := archiveFileStrategies
+	if info.Type != models.FileTypeFile {
+		strategies = archiveDirStrategies
+	}
+	cn, s, err := c.connFor(ctx, t, strategies)
+	if err != nil {
+		return err
+	}
+	slog.Debug("archive", slog.String("path", p), slog.String("strategy", s.name))
+
+	switch s {
+	case archiveTarGzip:
+		// Create a buffer for stderr
+		errBuf := new(bytes.Buffer)
+
+		// Stream directly to the writer without buffering the entire content in memory
+		err := c.exec(ctx, cn.Target, tarCreateCmd(cn.path(path.Dir(p)), path.Base(p), true), nil, w, errBuf)
+		if err != nil {
+			return execError(err, errBuf)
+		}
+		return nil
+	case archiveTar:
+		zw := gzip.NewWriter(w)
+		errBuf := new(bytes.Buffer)
+		err := c.exec(ctx, cn.Target, tarCreateCmd(cn.path(path.Dir(p)), path.Base(p), false), nil, zw, errBuf)
+		if err != nil {
+			return execError(err, errBuf)
+		}
+		return zw.Close()
+	default:
+		return c.archiveFile(ctx, cn, s, p, info, w)
+	}
+}
+
+// archiveFile writes a gzipped tarball of the regular file p to w, reading it with s.
+// The tarball holds info.Size bytes, a file changing meanwhile is truncated or zero padded.
+func (c *Client) archiveFile(ctx context.Context, cn conn, s *strategy, p string, info *models.FileInfo, w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	hdr := &tar.Header{
+		Name:    info.Name,
+		Mode:    int64(info.Mode.Perm()),
+		Size:    info.Size,
+		ModTime: time.Unix(info.Time, 0),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(c.read(ctx, cn, s, cn.path(p), pw))
+	}()
+	defer pr.Close()
+
+	n, err := io.CopyN(tw, pr, info.Size)
+	if err == io.EOF {
+		_, err = io.CopyN(tw, zeroReader{}, info.Size-n)
+	}
+	if err != nil {
+		return err
+	}
+	// drain the rest to get the error of the read, if any
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func (c *Client) Write(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode) error {
+	if err := c.write(ctx, t, p, r, size, mode); err != nil {
+		return err
+	}
+	c.event(ctx, t, ReasonUpload, "uploaded %s (%d bytes)", p, size)
+	return nil
+}
+
+func (c *Client) write(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode) error {
+	cn, s, err := c.connFor(ctx, t, writeStrategies)
+	if err != nil {
+		return err
+	}
+	if s == writeTee {
+		return c.writeTee(ctx, cn, cn.path(p), r, size, mode)
+	}
+
+	// Create a pipe for streaming data
+	pr, pw := io.Pipe()
+
+	// Use a goroutine to write data to the pipe
+	go func() {
+		// Use buffered writer to reduce memory pressure
+		bufWriter := bufio.NewWriterSize(pw, backend.FileBufferSize)
+		pw.CloseWithError(writeTar(bufWriter, path.Base(p), r, size, mode))
+	}()
+	defer pr.Close()
+
+	// Use buffered reader to control memory usage
+	bufReader := bufio.NewReaderSize(pr, backend.FileBufferSize)
+
+	errBuf := new(bytes.Buffer)
+
+	// Stream data directly from reader to pod without buffering entire content
+	err = c.exec(ctx, cn.Target, tarExtractCmd(cn.path(path.Dir(p))), bufReader, io.Discard, errBuf)
+	if err != nil {
+		return execError(err, errBuf)
+	}
+	return nil
+}
+
+func (c *Client) Replace(ctx context.Context, t models.Target, p string, r io.Reader, size int64, mode fs.FileMode, uid, gid int) error {
+	cn, _, err := c.connFor(ctx, t, replaceStrategies)
+	if err != nil {
+		return err
+	}
+	tmp := tempPath(p)
+	if err := c.write(ctx, t, tmp, r, size, mode); err != nil {
+		return err
+	}
+	// the temporary file belongs to the user of the exec, the owner is set before the mode
+	// since chown drops the setuid and setgid bits
+	err = c.keepOwner(ctx, t, cn, tmp, p, uid, gid)
+	if err == nil {
+		// the mode is set again as the write may drop its special bits or apply the umask
+		err = c.run(ctx, cn.Target, chmodCmd(mode, cn.path(tmp), false))
+	}
+	if err == nil {
+		err = c.run(ctx, cn.Target, mvCmd(cn.path(tmp), cn.path(p)))
+	}
+	if err != nil {
+		if rmErr := c.run(ctx, cn.Target, rmCmd(cn.path(tmp), false)); rmErr != nil {
+			slog.Warn("remove temporary file", slog.String("path", tmp), log.Error(rmErr))
+		}
+		return err
+	}
+	c.event(ctx, t, ReasonEdit, "edited %s (%d bytes)", p, size)
+	return nil
+}
+
+// keepOwner gives the temporary file tmp the owner uid and the group gid of the file p it replaces.
+// It has them already when the exec user isn't root and edits its own files, it may not chown then.
+func (c *Client) keepOwner(ctx context.Context, t models.Target, cn conn, tmp, p string, uid, gid int) error {
+	info, err := c.Stat(ctx, t, tmp)
+	if err != nil {
+		return err
+	}
+	if info.UID == uid && info.GID == gid {
+		return nil
+	}
+	if err := c.run(ctx, cn.Target, chownCmd(strconv.Itoa(uid), strconv.Itoa(gid), cn.path(tmp), false)); err != nil {
+		return fmt.Errorf("keep the owner %d:%d of %s: %w", uid, gid, p, err)
+	}
+	return nil
+}
+
+// tempPath returns a new hidden name next to p, for its content to be written before it is renamed to p.
+func tempPath(p string) string {
+	return path.Join(path.Dir(p), "."+path.Base(p)+".podfiles-"+strconv.FormatInt(rand.Int64(), 36))
+}
+
+// writeTee writes size bytes of r to file with tee, then sets its mode if chmod is there.
+func (c *Client) writeTee(ctx context.Context, cn conn, file string, r io.Reader, size int64, mode fs.FileMode) error {
+	errBuf := new(bytes.Buffer)
+	in := bufio.NewReaderSize(io.LimitReader(r, size), backend.FileBufferSize)
+	if err := c.exec(ctx, cn.Target, teeCmd(file), in, io.Discard, errBuf); err != nil {
+		return execError(err, errBuf)
+	}
+	// best effort, tee creates the file with the default mode
+	if err := c.run(ctx, cn.Target, chmodCmd(mode, file, false)); err != nil {
+		slog.Debug("chmod after tee", log.Error(err))
+	}
+	return nil
+}
+
+// writeTar writes a tarball holding a single file to the buffered w.
+func writeTar(w *bufio.Writer, name string, r io.Reader, size int64, mode fs.FileMode) error {
+	tw := tar.NewWriter(w)
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(mode.Perm()),
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header: %w", err)
+	}
+
+	// Use a buffer for copying to control memory usage
+	buf := make([]byte, backend.FileBufferSize)
+
+	// Use CopyBuffer instead of Copy for better memory control
+	if _, err := io.CopyBuffer(tw, io.LimitReader(r, size), buf); err != nil {
+		return fmt.Errorf("failed to copy file data: %w", err)
+	}
+
+	// Ensure all data is flushed
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush buffer: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) Remove(ctx context.Context, t models.Target, p string, recursive bool) error {
+	cn, _, err := c.connFor(ctx, t, removeStrategies)
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, cn.Target, rmCmd(cn.path(p), recursive)); err != nil {
+		return err
+	}
+	c.event(ctx, t, ReasonRemove, "removed %s", p)
+	return nil
+}
+
+func (c *Client) Rename(ctx context.Context, t models.Target, from, to string) error {
+	cn, _, err := c.connFor(ctx, t, renameStrategies)
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, cn.Target, renameCmd(cn.path(from), cn.path(to))); err != nil {
+		return err
+	}
+	// mv -n leaves from where it was if to exists
+	if _, err := c.Stat(ctx, t, from); err == nil {
+		return fmt.Errorf("rename %s to %s: %w", from, to, fs.ErrExist)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	c.event(ctx, t, ReasonRename, "renamed %s to %s", from, to)
+	return nil
+}
+
+func (c *Client) Mkdir(ctx context.Context, t models.Target, p string) error {
+	cn, _, err := c.connFor(ctx, t, mkdirStrategies)
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, cn.Target, mkdirCmd(cn.path(p))); err != nil {
+		return err
+	}
+	c.event(ctx, t, ReasonMkdir, "created the directory %s", p)
+	return nil
+}
+
+func (c *Client) Chmod(ctx context.Context, t models.Target, p string, mode fs.FileMode, recursive bool) error {
+	cn, _, err := c.connFor(ctx, t, chmodStrategies)
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, cn.Target, chmodCmd(mode, cn.path(p), recursive)); err != nil {
+		return err
+	}
+	c.event(ctx, t, ReasonChmod, "changed the mode of %s to %o", p, octalMode(mode))
+	return nil
+}
+
+func (c *Client) Chown(ctx context.Context, t models.Target, p, owner, group string, recursive bool) error {
+	cn, _, err := c.connFor(ctx, t, chownStrategies)
+	if err != nil {
+		return err
+	}
+	if err := c.run(ctx, cn.Target, chownCmd(owner, group, cn.path(p), recursive)); err != nil {
+		return err
+	}
+	c.event(ctx, t, ReasonChown, "changed the owner of %s to %s:%s", p, owner, group)
+	return nil
+}
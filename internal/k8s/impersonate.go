@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// localPrefix namespaces the local accounts of podFiles when impersonated,
+// so that signing up with the name of a cluster user or group doesn't get its RBAC.
+const localPrefix = "podfiles:"
+
+// userClient talks to the cluster as a user.
+type userClient struct {
+	clientset *kubernetes.Clientset
+	config    *rest.Config
+}
+
+// as returns the client to act for the identity of t: podFiles' own one,
+// or with impersonation on, one impersonating the user and groups.
+// The impersonating clients are made per call on top of the shared http client,
+// so that they share its connections and nothing is kept for the users.
+func (c *Client) as(t models.Target) (*userClient, error) {
+	if !conf.Impersonate() {
+		return &userClient{clientset: c.clientset, config: c.config}, nil
+	}
+	id := t.Identity
+	if id == nil || id.User == "" {
+		return nil, backend.ErrNoIdentity
+	}
+
+	user, groups := impersonated(id)
+	// the config impersonates for the execs, which dial their own connections
+	config := *c.config
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: user,
+		Groups:   groups,
+	}
+	base := c.httpClient.Transport
+	if base == nil {
+		// rest.HTTPClientFor returns http.DefaultClient for plain configs
+		base = http.DefaultTransport
+	}
+	httpClient := *c.httpClient
+	httpClient.Transport = transport.NewImpersonatingRoundTripper(transport.ImpersonationConfig{
+		UserName: user,
+		Groups:   groups,
+	}, base)
+	clientset, err := kubernetes.NewForConfigAndClient(&config, &httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &userClient{clientset: clientset, config: &config}, nil
+}
+
+// impersonated returns the user and groups to impersonate for id: local accounts get the podfiles: prefix,
+// and only the groups of conf.ImpersonateGroups are kept.
+func impersonated(id *models.Identity) (string, []string) {
+	user := id.User
+	if id.Local {
+		user = localPrefix + user
+	}
+	allowed := conf.ImpersonateGroups()
+	var groups []string
+	for _, g := range id.Groups {
+		if id.Local {
+			g = localPrefix + g
+		}
+		if slices.Contains(allowed, g) {
+			groups = append(groups, g)
+		}
+	}
+	return user, groups
+}
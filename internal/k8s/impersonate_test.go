@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestAs(t *testing.T) {
+	// the api server records the impersonation headers
+	var mu sync.Mutex
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = r.Header.Clone()
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"NamespaceList","apiVersion":"v1","items":[]}`))
+	}))
+	defer srv.Close()
+	c, err := newClient(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonated := func(uc *userClient) (string, []string) {
+		t.Helper()
+		if _, err := uc.clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return headers.Get("Impersonate-User"), headers.Values("Impersonate-Group")
+	}
+	alice := &models.Identity{User: "alice", Groups: []string{"qa"}}
+
+	uc, err := c.as(models.Target{Identity: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := impersonated(uc); user != "" || uc.config.Impersonate.UserName != "" {
+		t.Errorf("impersonating %q while impersonation is off", user)
+	}
+
+	t.Setenv("IMPERSONATE", "true")
+	t.Setenv("IMPERSONATE_GROUPS", "qa,podfiles:dev")
+	if _, err := c.as(models.Target{}); !errors.Is(err, backend.ErrNoIdentity) {
+		t.Errorf("as() without identity = %v, want %v", err, backend.ErrNoIdentity)
+	}
+	uc, err = c.as(models.Target{Identity: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uc.config.Impersonate; got.UserName != "alice" || !slices.Equal(got.Groups, []string{"qa"}) {
+		t.Errorf("exec config impersonating %+v, want alice in qa", got)
+	}
+	if user, groups := impersonated(uc); user != "alice" || !slices.Equal(groups, []string{"qa"}) {
+		t.Errorf("impersonating %q in %q, want alice in qa", user, groups)
+	}
+	other, err := c.as(models.Target{Identity: &models.Identity{User: "bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, groups := impersonated(other); user != "bob" || len(groups) > 0 {
+		t.Errorf("impersonating %q in %q, want bob alone", user, groups)
+	}
+	// local accounts are apart from the cluster users, the groups not listed are dropped
+	local, err := c.as(models.Target{Identity: &models.Identity{User: "alice", Groups: []string{"dev", "qa", "system:masters"}, Local: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, groups := impersonated(local); user != "podfiles:alice" || !slices.Equal(groups, []string{"podfiles:dev"}) {
+		t.Errorf("impersonating %q in %q, want podfiles:alice in podfiles:dev", user, groups)
+	}
+	masters, err := c.as(models.Target{Identity: &models.Identity{User: "bob", Groups: []string{"system:masters"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, groups := impersonated(masters); user != "bob" || len(groups) > 0 {
+		t.Errorf("impersonating %q in %q, want bob alone", user, groups)
+	}
+	if user, _ := impersonated(&userClient{clientset: c.clientset, config: c.config}); user != "" || c.config.Impersonate.UserName != "" {
+		t.Errorf("the base client is impersonating %q", user)
+	}
+}
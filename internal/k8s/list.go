@@ -0,0 +1,305 @@
+package k8s
+
+import (
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+// fieldSep separates the fields of a listing record. The unit separator is
+// passed to stat as a raw byte in argv, so names with spaces survive intact.
+const fieldSep = "\x1f"
+
+// recordSep ends the listing records, before the line break stat adds,
+// so that names with line breaks survive too. stat of busybox has no --printf for a NUL.
+const recordSep = "\x1e"
+
+// statFormat makes stat emit one machine-readable record per entry:
+// raw mode (hex), size in bytes, mtime (epoch), uid, gid, user name, group name, name, quoted name.
+// The quoted name comes last, it carries the target for symlinks.
+var statFormat = strings.Join([]string{"%f", "%s", "%Y", "%u", "%g", "%U", "%G", "%n", "%N"}, fieldSep) + recordSep
+
+const statFields = 9
+
+// unknownID is what stat prints for the ids without a name in the container.
+const unknownID = "UNKNOWN"
+
+// fileTypeMap maps file type indicators from ls output to readable types
+var fileTypeMap = map[byte]string{
+	'd': models.FileTypeDir,
+	'-': models.FileTypeFile,
+	'l': models.FileTypeLink,
+	'c': models.FileTypeChar,
+	'b': models.FileTypeBlock,
+	's': models.FileTypeSocket,
+	'p': models.FileTypeFifo,
+}
+
+// listCmd returns the probe listing the direct children of dir.
+// It works with the find and stat of GNU coreutils, busybox and toybox.
+func listCmd(dir string) []string {
+	return []string{"find", dir, "-mindepth", "1", "-maxdepth", "1", "-exec", "stat", "-c", statFormat, "--", "{}", "+"}
+}
+
+// linkDirsCmd returns the probe printing the symlinks in dir that resolve to directories.
+func linkDirsCmd(dir string) []string {
+	return []string{"find", dir, "-mindepth", "1", "-maxdepth", "1", "-type", "l", "-exec", "stat", "-L", "-c", "%f" + fieldSep + "%n" + recordSep, "--", "{}", "+"}
+}
+
+// statCmd returns the probe describing a single file in the format of listCmd.
+func statCmd(file string) []string {
+	return []string{"stat", "-c", statFormat, "--", file}
+}
+
+// lsStatCmd returns the fallback of statCmd.
+func lsStatCmd(file string) []string {
+	return []string{"ls", "-lnd", "--", file}
+}
+
+// realPathCmd returns the command printing file with its symlinks resolved.
+// It prints nothing and fails silently when a parent of file doesn't exist.
+func realPathCmd(file string) []string {
+	return []string{"readlink", "-f", "--", file}
+}
+
+// lsCmd returns the fallback listing command for containers without find or stat.
+// Its lines are cut at the line breaks of the names, the parts that don't parse are skipped.
+func lsCmd(dir string) []string {
+	return []string{"ls", "-lna", "--", dir}
+}
+
+// records splits the output of the stat probes into the fields of their records.
+// The lines printed before a record, such as warnings, are dropped.
+func records(output string, n int) [][]string {
+	var recs [][]string
+	for _, rec := range strings.Split(output, recordSep+"\n") {
+		fields := strings.SplitN(rec, fieldSep, n)
+		if len(fields) < n {
+			continue
+		}
+		fields[0] = fields[0][strings.LastIndexByte(fields[0], '\n')+1:]
+		recs = append(recs, fields)
+	}
+	return recs
+}
+
+// parseStatList parses the output of listCmd.
+func parseStatList(output string) []models.FileInfo {
+	files := []models.FileInfo{}
+	for _, fields := range records(output, statFields) {
+		raw, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		mtime, _ := strconv.ParseInt(fields[2], 10, 64)
+		uid, _ := strconv.Atoi(fields[3])
+		gid, _ := strconv.Atoi(fields[4])
+		mode := unixMode(uint32(raw))
+		file := models.FileInfo{
+			Name:  path.Base(fields[7]),
+			Type:  models.FileType(mode),
+			Size:  size,
+			Time:  mtime,
+			Mode:  mode,
+			UID:   uid,
+			GID:   gid,
+			Owner: idName(fields[5]),
+			Group: idName(fields[6]),
+		}
+		if file.Type == models.FileTypeLink {
+			file.LinkTarget = linkTarget(fields[7], fields[8])
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// idName returns the user or group name printed by stat, empty if it is unknown.
+func idName(name string) string {
+	if name == unknownID {
+		return ""
+	}
+	return name
+}
+
+// parseLinkDirs parses the output of linkDirsCmd, returning the names
+// of the symlinks that point to directories.
+func parseLinkDirs(output string) map[string]bool {
+	dirs := map[string]bool{}
+	for _, fields := range records(output, 2) {
+		raw, name := fields[0], fields[1]
+		mode, err := strconv.ParseUint(raw, 16, 32)
+		if err != nil {
+			continue
+		}
+		if unixMode(uint32(mode)).IsDir() {
+			dirs[path.Base(name)] = true
+		}
+	}
+	return dirs
+}
+
+// unixMode converts a raw st_mode to fs.FileMode.
+func unixMode(raw uint32) fs.FileMode {
+	mode := fs.FileMode(raw & 0o777)
+	switch raw & 0o170000 {
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	case 0o020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o060000:
+		mode |= fs.ModeDevice
+	case 0o140000:
+		mode |= fs.ModeSocket
+	case 0o010000:
+		mode |= fs.ModeNamedPipe
+	}
+	if raw&0o4000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if raw&0o2000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if raw&0o1000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+// linkTarget extracts the symlink target from stat's %N output,
+// which looks like `'name' -> 'target'`.
+func linkTarget(name, quoted string) string {
+	// the quoted name is at least as long as the name itself
+	if len(quoted) < len(name) {
+		return ""
+	}
+	i := strings.Index(quoted[len(name):], " -> ")
+	if i < 0 {
+		return ""
+	}
+	return unquote(quoted[len(name)+i+len(" -> "):])
+}
+
+// unquote removes the shell quoting that stat applies to %N.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return strings.ReplaceAll(s, `'\''`, `'`)
+}
+
+// parseFileList parses the output of `ls -ln`, it is only used when the
+// stat probe is not available in the container.
+func parseFileList(output string, now time.Time) []models.FileInfo {
+	files := []models.FileInfo{}
+	lines := strings.Split(output, "\n")
+	for _, line := range lines {
+		// Skip empty lines and lines starting with "total"
+		if line == "" || strings.HasPrefix(line, "total") {
+			continue
+		}
+		fields := strings.Fields(line)
+		// Skip lines with insufficient fields
+		if len(fields) < 9 {
+			continue
+		}
+		fileType, ok := fileTypeMap[fields[0][0]]
+		if !ok {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[4], 10, 64)
+		uid, _ := strconv.Atoi(fields[2])
+		gid, _ := strconv.Atoi(fields[3])
+		name := strings.Join(fields[8:], " ")
+		file := models.FileInfo{
+			Name: name,
+			Type: fileType,
+			Size: size,
+			Time: parseLsTime(fields[5], fields[6], fields[7], now),
+			Mode: parsePerm(fields[0]),
+			UID:  uid,
+			GID:  gid,
+		}
+		if fileType == models.FileTypeLink {
+			if name, target, ok := strings.Cut(name, " -> "); ok {
+				file.Name = name
+				file.LinkTarget = target
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// parseLsTime parses the "Jan _2 15:04" or "Jan _2 2006" time columns of ls.
+// Entries without a year are within the last six months of now.
+func parseLsTime(month, day, clock string, now time.Time) int64 {
+	if t, err := time.Parse("Jan 2 2006", month+" "+day+" "+clock); err == nil {
+		return t.Unix()
+	}
+	t, err := time.Parse("Jan 2 15:04", month+" "+day+" "+clock)
+	if err != nil {
+		return 0
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.AddDate(0, 0, 1)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t.Unix()
+}
+
+// parsePerm converts a permission string like "drwxr-xr-x" to fs.FileMode.
+func parsePerm(perm string) fs.FileMode {
+	if len(perm) < 10 {
+		return 0
+	}
+	var mode fs.FileMode
+	switch fileTypeMap[perm[0]] {
+	case models.FileTypeDir:
+		mode |= fs.ModeDir
+	case models.FileTypeLink:
+		mode |= fs.ModeSymlink
+	case models.FileTypeChar:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case models.FileTypeBlock:
+		mode |= fs.ModeDevice
+	case models.FileTypeSocket:
+		mode |= fs.ModeSocket
+	case models.FileTypeFifo:
+		mode |= fs.ModeNamedPipe
+	}
+	for i, c := range perm[1:10] {
+		bit := fs.FileMode(1) << (8 - i)
+		switch c {
+		case 'r', 'w', 'x':
+			mode |= bit
+		case 's', 't':
+			mode |= bit
+			mode |= specialBit(i)
+		case 'S', 'T':
+			mode |= specialBit(i)
+		}
+	}
+	return mode
+}
+
+// specialBit returns the setuid, setgid or sticky bit carried by
+// the execute position i of a permission string.
+func specialBit(i int) fs.FileMode {
+	switch i {
+	case 2:
+		return fs.ModeSetuid
+	case 5:
+		return fs.ModeSetgid
+	case 8:
+		return fs.ModeSticky
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+package k8s
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+func TestParseStatList(t *testing.T) {
+	rec := func(fields ...string) string {
+		s := fields[0]
+		for _, f := range fields[1:] {
+			s += fieldSep + f
+		}
+		return s + recordSep + "\n"
+	}
+	tests := []struct {
+		name   string
+		output string
+		want   []models.FileInfo
+	}{
+		{
+			name:   "empty",
+			output: "",
+			want:   []models.FileInfo{},
+		},
+		{
+			name: "dir and file",
+			output: rec("41ed", "4096", "1700000000", "0", "0", "root", "root", "/etc/ssl", "'/etc/ssl'") +
+				rec("81a4", "1234567", "1600000000", "1000", "1000", "UNKNOWN", "UNKNOWN", "/etc/my file.conf", "'/etc/my file.conf'"),
+			want: []models.FileInfo{
+				{Name: "ssl", Type: models.FileTypeDir, Size: 4096, Time: 1700000000, Mode: fs.ModeDir | 0o755, Owner: "root", Group: "root"},
+				{Name: "my file.conf", Type: models.FileTypeFile, Size: 1234567, Time: 1600000000, Mode: 0o644, UID: 1000, GID: 1000},
+			},
+		},
+		{
+			name:   "symlink",
+			output: rec("a1ff", "7", "1700000000", "0", "0", "root", "root", "/lib/it's", `'/lib/it'\''s' -> '/usr/lib'`),
+			want: []models.FileInfo{
+				{Name: "it's", Type: models.FileTypeLink, Size: 7, Time: 1700000000, Mode: fs.ModeSymlink | 0o777, LinkTarget: "/usr/lib", Owner: "root", Group: "root"},
+			},
+		},
+		{
+			name:   "line break in name",
+			output: rec("81a4", "3", "1700000000", "0", "0", "root", "root", "/tmp/a\nb", "'/tmp/a'$'\\n''b'") + rec("81a4", "0", "1700000000", "0", "0", "root", "root", "/tmp/c", "'/tmp/c'"),
+			want: []models.FileInfo{
+				{Name: "a\nb", Type: models.FileTypeFile, Size: 3, Time: 1700000000, Mode: 0o644, Owner: "root", Group: "root"},
+				{Name: "c", Type: models.FileTypeFile, Time: 1700000000, Mode: 0o644, Owner: "root", Group: "root"},
+			},
+		},
+		{
+			name:   "char device and garbage",
+			output: "warning: garbage\n" + rec("21b6", "0", "1700000000", "0", "0", "root", "root", "/dev/null", "'/dev/null'") + "stat: cannot stat\n",
+			want: []models.FileInfo{
+				{Name: "null", Type: models.FileTypeChar, Time: 1700000000, Mode: fs.ModeDevice | fs.ModeCharDevice | 0o666, Owner: "root", Group: "root"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseStatList(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStatList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileList(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	output := `total 8
+drwxr-xr-x    2 0        0             4096 Feb 28 10:30 bin
+-rw-r--r--    1 1000     1000       1048576 Dec 24 08:00 app log.txt
+-rwsr-xr-x    1 0        0             1024 Jan  2  2020 su
+lrwxrwxrwx    1 0        0               12 Feb  1 09:00 lib -> /usr/lib
+`
+	want := []models.FileInfo{
+		{Name: "bin", Type: models.FileTypeDir, Size: 4096, Time: time.Date(2024, 2, 28, 10, 30, 0, 0, time.UTC).Unix(), Mode: fs.ModeDir | 0o755},
+		{Name: "app log.txt", Type: models.FileTypeFile, Size: 1048576, Time: time.Date(2023, 12, 24, 8, 0, 0, 0, time.UTC).Unix(), Mode: 0o644, UID: 1000, GID: 1000},
+		{Name: "su", Type: models.FileTypeFile, Size: 1024, Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix(), Mode: fs.ModeSetuid | 0o755},
+		{Name: "lib", Type: models.FileTypeLink, Size: 12, Time: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC).Unix(), Mode: fs.ModeSymlink | 0o777, LinkTarget: "/usr/lib"},
+	}
+	if got := parseFileList(output, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseFileList() = %+v, want %+v", got, want)
+	}
+}
+
+// TestListCmd runs the listing probe against the local find and stat.
+func TestListCmd(t *testing.T) {
+	if _, err := exec.LookPath("find"); err != nil {
+		t.Skip("find not available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a b"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/tmp", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "two\nlines"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := listCmd(dir)
+	out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]models.FileInfo{}
+	for _, f := range parseStatList(string(out)) {
+		got[f.Name] = f
+	}
+	if f := got["a b"]; f.Type != models.FileTypeFile || f.Size != 5 || f.Mode != 0o600 || f.Time == 0 {
+		t.Errorf("file = %+v", f)
+	}
+	if f := got["sub"]; f.Type != models.FileTypeDir || f.Mode != fs.ModeDir|0o755 {
+		t.Errorf("dir = %+v", f)
+	}
+	if f := got["link"]; f.Type != models.FileTypeLink || f.LinkTarget != "/tmp" {
+		t.Errorf("link = %+v", f)
+	}
+	if f, ok := got["two\nlines"]; !ok || f.Type != models.FileTypeFile || len(got) != 4 {
+		t.Errorf("files = %+v, want the name with a line break", got)
+	}
+}
+
+// TestLinkDirsCmd runs the symlink probe against the local find and stat.
+func TestLinkDirsCmd(t *testing.T) {
+	if _, err := exec.LookPath("find"); err != nil {
+		t.Skip("find not available")
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "inner"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{"to-dir": "sub", "to-file": "file", "dangling": "missing"} {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the dangling link makes the probe exit non-zero, its output is still usable
+	cmd := linkDirsCmd(dir + "/")
+	out, _ := exec.Command(cmd[0], cmd[1:]...).Output()
+	want := map[string]bool{"to-dir": true}
+	if got := parseLinkDirs(string(out)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLinkDirs() = %v, want %v", got, want)
+	}
+
+	// listing through a symlinked directory shows the target's content
+	cmd = listCmd(filepath.Join(dir, "to-dir") + "/")
+	out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseStatList(string(out)); len(got) != 1 || got[0].Name != "inner" {
+		t.Errorf("list symlinked dir = %+v, want inner", got)
+	}
+}
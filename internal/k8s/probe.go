@@ -0,0 +1,272 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// probedTools are the tools podFiles may use in a container.
+var probedTools = []string{"find", "stat", "ls", "tar", "gzip", "cat", "base64", "tee", "chmod", "chown", "rm", "mv", "mkdir", "tail", "head", "readlink"}
+
+// probeScript prints the tools among its arguments that are available.
+// The names are passed as arguments, the script itself is constant.
+const probeScript = `for t in "$@"; do command -v "$t" >/dev/null 2>&1 && echo "$t"; done`
+
+func probeCmd() []string {
+	return append([]string{"sh", "-c", probeScript, "sh"}, probedTools...)
+}
+
+// toolset is the set of the available tools in a container.
+type toolset map[string]bool
+
+func parseToolset(output string) toolset {
+	ts := toolset{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if slices.Contains(probedTools, line) {
+			ts[line] = true
+		}
+	}
+	return ts
+}
+
+// has reports whether all the tools are available.
+func (ts toolset) has(tools ...string) bool {
+	for _, t := range tools {
+		if !ts[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// strategy is a way to do an operation, with the tools it needs.
+type strategy struct {
+	name  string
+	tools []string
+}
+
+var (
+	listFindStat = &strategy{"find+stat", []string{"find", "stat"}}
+	listLs       = &strategy{"ls", []string{"ls"}}
+	statStat     = &strategy{"stat", []string{"stat"}}
+	// realPathReadlink isn't an operation of the capabilities, the path rules need it for every other
+	realPathReadlink = &strategy{"readlink", []string{"readlink"}}
+	readCat          = &strategy{"cat", []string{"cat"}}
+	readBase64       = &strategy{"base64", []string{"base64"}}
+	// readTailHead reads a range in the container, readCat and readBase64 read the file up to its end
+	readTailHead   = &strategy{"tail+head", []string{"tail", "head"}}
+	tailTail       = &strategy{"tail", []string{"tail"}}
+	archiveTarGzip = &strategy{"tar+gzip", []string{"tar", "gzip"}}
+	// archiveTar lets tar write a plain tarball, compressed by podFiles
+	archiveTar = &strategy{"tar", []string{"tar"}}
+	writeUntar = &strategy{"tar", []string{"tar"}}
+	writeTee   = &strategy{"tee", []string{"tee"}}
+	removeRm   = &strategy{"rm", []string{"rm"}}
+	renameMv   = &strategy{"mv", []string{"mv"}}
+	mkdirMkdir = &strategy{"mkdir", []string{"mkdir"}}
+	chmodChmod = &strategy{"chmod", []string{"chmod"}}
+	chownChown = &strategy{"chown", []string{"chown"}}
+	// replaceMv sets the owner and the mode of the temporary file and renames it, after it is written as an upload
+	replaceMv = &strategy{"mv+chmod+chown", []string{"mv", "chmod", "chown"}}
+)
+
+// The strategies of each operation, by preference.
+var (
+	listStrategies     = []*strategy{listFindStat, listLs}
+	statStrategies     = []*strategy{statStat, listLs}
+	realPathStrategies = []*strategy{realPathReadlink}
+	readStrategies     = []*strategy{readCat, readBase64}
+	rangeStrategies    = []*strategy{readTailHead, readCat, readBase64}
+	tailStrategies     = []*strategy{tailTail}
+	// directories can only be archived by tar, single files are read and archived by podFiles
+	archiveDirStrategies  = []*strategy{archiveTarGzip, archiveTar}
+	archiveFileStrategies = []*strategy{archiveTarGzip, archiveTar, readCat, readBase64}
+	writeStrategies       = []*strategy{writeUntar, writeTee}
+	removeStrategies      = []*strategy{removeRm}
+	renameStrategies      = []*strategy{renameMv}
+	mkdirStrategies       = []*strategy{mkdirMkdir}
+	chmodStrategies       = []*strategy{chmodChmod}
+	chownStrategies       = []*strategy{chownChown}
+	replaceStrategies     = []*strategy{replaceMv}
+)
+
+// operations names the strategies of the operations, as reported in the capabilities.
+var operations = []struct {
+	name       string
+	strategies []*strategy
+}{
+	{"list", listStrategies},
+	{"read", readStrategies},
+	{"tail", tailStrategies},
+	{"download", archiveDirStrategies},
+	{"upload", writeStrategies},
+	{"remove", removeStrategies},
+	{"rename", renameStrategies},
+	{"mkdir", mkdirStrategies},
+	{"chmod", chmodStrategies},
+	{"chown", chownStrategies},
+	{"edit", replaceStrategies},
+}
+
+// pick returns the first of the strategies that ts supports, or nil.
+func pick(ts toolset, strategies []*strategy) *strategy {
+	for _, s := range strategies {
+		if ts.has(s.tools...) {
+			return s
+		}
+	}
+	return nil
+}
+
+// missingTools returns the tools of the strategies that ts lacks.
+func missingTools(ts toolset, strategies []*strategy) []string {
+	var missing []string
+	for _, s := range strategies {
+		for _, t := range s.tools {
+			if !ts[t] && !slices.Contains(missing, t) {
+				missing = append(missing, t)
+			}
+		}
+	}
+	return missing
+}
+
+func (ts toolset) capabilities() *models.Capabilities {
+	caps := &models.Capabilities{Tools: []string{}, Missing: []string{}, Unsupported: []string{}}
+	for _, t := range probedTools {
+		if ts[t] {
+			caps.Tools = append(caps.Tools, t)
+		} else {
+			caps.Missing = append(caps.Missing, t)
+		}
+	}
+	for _, op := range operations {
+		if pick(ts, op.strategies) == nil {
+			caps.Unsupported = append(caps.Unsupported, op.name)
+		}
+	}
+	return caps
+}
+
+// tools returns the tools of the container cn, probing it again after probeTTL, by pod UID and container.
+func (c *Client) tools(ctx context.Context, cn conn, uid string) (toolset, error) {
+	key := uid + "/" + cn.Container
+	if ts, ok := c.toolsets.Get(key); ok {
+		return ts.(toolset), nil
+	}
+
+	output, err := c.output(ctx, cn.Target, probeCmd())
+	var ts toolset
+	var missing *backend.ToolMissingError
+	switch {
+	case errors.As(err, &missing):
+		// no shell, as in distroless images, take it as no tools at all
+		ts = toolset{}
+	case err != nil:
+		return nil, err
+	default:
+		ts = parseToolset(output)
+	}
+	slog.Debug("probe tools", slog.String("container", targetKey(cn.Target)), slog.Any("tools", ts))
+
+	c.toolsets.SetDefault(key, ts)
+	return ts, nil
+}
+
+// getPod returns the pod of t as its user sees it, reused for podTTL.
+// The pod is shared, it must not be modified.
+func (c *Client) getPod(ctx context.Context, t models.Target) (*corev1.Pod, error) {
+	key := podKey(t)
+	if pod, ok := c.pods.Get(key); ok {
+		return pod.(*corev1.Pod), nil
+	}
+	uc, err := c.as(t)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := uc.clientset.CoreV1().Pods(t.Namespace).Get(ctx, t.Pod, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.pods.SetDefault(key, pod)
+	return pod, nil
+}
+
+// podKey returns the key of the pod of t in the cache, apart for every impersonated user
+// as they may not see the same pods.
+func podKey(t models.Target) string {
+	key := t.Namespace + "/" + t.Pod
+	if conf.Impersonate() && t.Identity != nil {
+		user, groups := impersonated(t.Identity)
+		key = user + "\x00" + strings.Join(groups, ",") + "\x00" + key
+	}
+	return key
+}
+
+// connFor returns where to run an operation in the container t and the strategy
+// to use there: the container itself if its tools support one of the strategies,
+// or else a debug container when enabled.
+func (c *Client) connFor(ctx context.Context, t models.Target, strategies []*strategy) (conn, *strategy, error) {
+	pod, err := c.getPod(ctx, t)
+	if err != nil {
+		return conn{}, nil, err
+	}
+	uid := string(pod.UID)
+
+	cn := conn{Target: t}
+	ts, err := c.tools(ctx, cn, uid)
+	if err != nil {
+		return conn{}, nil, err
+	}
+	if s := pick(ts, strategies); s != nil {
+		return cn, s, nil
+	}
+	missing := &backend.ToolMissingError{Tools: missingTools(ts, strategies)}
+	if !conf.DebugContainerEnabled() {
+		return conn{}, nil, missing
+	}
+
+	name, err := c.debugContainer(ctx, t, pod)
+	if err != nil {
+		return conn{}, nil, fmt.Errorf("%w, failed to start a debug container: %v", missing, err)
+	}
+	cn = debugConn(t, name)
+	ts, err = c.tools(ctx, cn, uid)
+	if err != nil {
+		return conn{}, nil, err
+	}
+	if s := pick(ts, strategies); s != nil {
+		return cn, s, nil
+	}
+	return conn{}, nil, &backend.ToolMissingError{Tools: missingTools(ts, strategies)}
+}
+
+func (c *Client) Capabilities(ctx context.Context, t models.Target) (*models.Capabilities, error) {
+	pod, err := c.getPod(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := c.tools(ctx, conn{Target: t}, string(pod.UID))
+	if err != nil {
+		return nil, err
+	}
+	caps := ts.capabilities()
+	if len(caps.Unsupported) > 0 && conf.DebugContainerEnabled() {
+		// the debug image is expected to have everything
+		caps.Debug = true
+		caps.Unsupported = []string{}
+	}
+	return caps, nil
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		strategies []*strategy
+		want       *strategy
+		missing    []string
+	}{
+		{
+			name:       "coreutils",
+			output:     "find\nstat\nls\ntar\ngzip\ncat\n",
+			strategies: archiveFileStrategies,
+			want:       archiveTarGzip,
+		},
+		{
+			name:       "tar without gzip",
+			output:     "tar\ncat\n",
+			strategies: archiveDirStrategies,
+			want:       archiveTar,
+		},
+		{
+			name:       "single file without tar",
+			output:     "base64\n",
+			strategies: archiveFileStrategies,
+			want:       readBase64,
+		},
+		{
+			name:       "directory without tar",
+			output:     "cat\nbase64\n",
+			strategies: archiveDirStrategies,
+			missing:    []string{"tar", "gzip"},
+		},
+		{
+			name:       "ls only",
+			output:     "ls\n",
+			strategies: listStrategies,
+			want:       listLs,
+		},
+		{
+			name:       "upload with tee",
+			output:     "tee\nchmod\n",
+			strategies: writeStrategies,
+			want:       writeTee,
+		},
+		{
+			name:       "no shell",
+			output:     "",
+			strategies: readStrategies,
+			missing:    []string{"cat", "base64"},
+		},
+		{
+			name:       "noise",
+			output:     "/bin/cat\nsh: stat: not found\n",
+			strategies: readStrategies,
+			missing:    []string{"cat", "base64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := parseToolset(tt.output)
+			if got := pick(ts, tt.strategies); got != tt.want {
+				t.Errorf("pick() = %v, want %v", got, tt.want)
+			}
+			if tt.want == nil {
+				if got := missingTools(ts, tt.strategies); !slices.Equal(got, tt.missing) {
+					t.Errorf("missingTools() = %q, want %q", got, tt.missing)
+				}
+			}
+		})
+	}
+}
+
+// TestProbeCmd runs the probe with the local shell.
+func TestProbeCmd(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	cmd := probeCmd()
+	out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := parseToolset(string(out))
+	for _, tool := range probedTools {
+		_, err := exec.LookPath(tool)
+		if ts[tool] != (err == nil) {
+			t.Errorf("probed %s = %v, want %v", tool, ts[tool], err == nil)
+		}
+	}
+}
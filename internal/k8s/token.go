@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+	"github.com/zrcoder/podFiles/internal/models"
+
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReviewToken returns the identity of a bearer token, as reviewed by the default cluster.
+func (cs *Clusters) ReviewToken(ctx context.Context, token string) (*models.Identity, error) {
+	return cs.clients[cs.def].ReviewToken(ctx, token)
+}
+
+// ReviewToken returns the identity of a bearer token with the TokenReview api.
+// The review is made by podFiles itself, never impersonating.
+func (c *Client) ReviewToken(ctx context.Context, token string) (*models.Identity, error) {
+	if token == "" {
+		return nil, backend.ErrInvalidToken
+	}
+	review, err := c.clientset.AuthenticationV1().TokenReviews().Create(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("token review: %w", err)
+	}
+	if !review.Status.Authenticated {
+		slog.Debug("token review", slog.String("error", review.Status.Error))
+		return nil, backend.ErrInvalidToken
+	}
+	return &models.Identity{User: review.Status.User.Username, Groups: review.Status.User.Groups}, nil
+}
@@ -0,0 +1,60 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/zrcoder/podFiles/internal/backend"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestReviewToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/apis/authentication.k8s.io/v1/tokenreviews" {
+			http.NotFound(w, r)
+			return
+		}
+		var review authv1.TokenReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if review.Spec.Token == "good" {
+			review.Status = authv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authv1.UserInfo{Username: "alice", Groups: []string{"dev", "system:authenticated"}},
+			}
+		} else {
+			review.Status.Error = "token expired"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(review)
+	}))
+	defer srv.Close()
+
+	c, err := newClient(&rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	id, err := c.ReviewToken(ctx, "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.User != "alice" || !slices.Equal(id.Groups, []string{"dev", "system:authenticated"}) {
+		t.Errorf("identity = %+v, want alice in dev", id)
+	}
+	for _, token := range []string{"bad", ""} {
+		if _, err := c.ReviewToken(ctx, token); !errors.Is(err, backend.ErrInvalidToken) {
+			t.Errorf("ReviewToken(%q) error = %v, want %v", token, err, backend.ErrInvalidToken)
+		}
+	}
+}
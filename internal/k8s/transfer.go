@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+)
+
+// tarCreateCmd returns the command writing a tarball of name, relative to dir, to stdout,
+// gzipped if compress. Paths are passed as separate arguments, nothing is interpreted by a shell.
+func tarCreateCmd(dir, name string, compress bool) []string {
+	flags := "-cf"
+	if compress {
+		flags = "-czf"
+	}
+	return []string{"tar", "-C", dir, flags, "-", "--", name}
+}
+
+// tarExtractCmd returns the command extracting a tarball from stdin into dir.
+func tarExtractCmd(dir string) []string {
+	return []string{"tar", "-xf", "-", "-C", dir}
+}
+
+// catCmd returns the command writing the content of file to stdout.
+func catCmd(file string) []string {
+	return []string{"cat", "--", file}
+}
+
+// rangeScript writes the bytes of a file from an offset, counted from 1, up to a limit.
+// The offset, the file and the limit are passed as arguments, the script itself is constant.
+const rangeScript = `tail -c +"$1" -- "$2" | head -c "$3"`
+
+// rangeCmd returns the command writing at most limit bytes of file from offset to stdout.
+func rangeCmd(file string, offset, limit int64) []string {
+	return []string{"sh", "-c", rangeScript, "sh", strconv.FormatInt(offset+1, 10), file, strconv.FormatInt(limit, 10)}
+}
+
+// tailCmd returns the command writing the last lines of file to stdout, then following it by name.
+func tailCmd(file string, lines int) []string {
+	return []string{"tail", "-n", strconv.Itoa(lines), "-F", "--", file}
+}
+
+// base64Cmd returns the command writing the content of file to stdout in base64.
+func base64Cmd(file string) []string {
+	return []string{"base64", "--", file}
+}
+
+// teeCmd returns the command writing stdin to file.
+func teeCmd(file string) []string {
+	return []string{"tee", "--", file}
+}
+
+// chmodCmd returns the command setting the permissions of file to mode, including the setuid, setgid and sticky bits.
+// With recursive, the permissions of everything in the directory file are set as well.
+func chmodCmd(mode fs.FileMode, file string, recursive bool) []string {
+	cmd := []string{"chmod"}
+	if recursive {
+		cmd = append(cmd, "-R")
+	}
+	return append(cmd, fmt.Sprintf("%o", octalMode(mode)), "--", file)
+}
+
+// octalMode returns the permission bits of mode as chmod takes them.
+func octalMode(mode fs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
+
+// chownCmd returns the command setting the owner and the group of file, either may be empty to keep it.
+// With recursive, the owner and the group of everything in the directory file are set as well.
+func chownCmd(owner, group, file string, recursive bool) []string {
+	cmd := []string{"chown"}
+	if recursive {
+		cmd = append(cmd, "-R")
+	}
+	spec := owner
+	if group != "" {
+		spec += ":" + group
+	}
+	return append(cmd, spec, "--", file)
+}
+
+// rmCmd returns the command removing file, directories need recursive.
+func rmCmd(file string, recursive bool) []string {
+	if recursive {
+		return []string{"rm", "-rf", "--", file}
+	}
+	return []string{"rm", "-f", "--", file}
+}
+
+// mvCmd returns the command renaming from to to, replacing to.
+func mvCmd(from, to string) []string {
+	return []string{"mv", "--", from, to}
+}
+
+// renameCmd returns the command renaming from to to, leaving to alone if it exists:
+// -T never moves into a directory and -n never replaces, mv then exits 0 or,
+// as some coreutils versions do, fails with "not replacing".
+func renameCmd(from, to string) []string {
+	return []string{"mv", "-n", "-T", "--", from, to}
+}
+
+// mkdirCmd returns the command creating the directory dir.
+func mkdirCmd(dir string) []string {
+	return []string{"mkdir", "--", dir}
+}
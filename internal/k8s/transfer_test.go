@@ -0,0 +1,180 @@
+package k8s
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var hostileNames = []string{
+	"a; rm -rf ~",
+	"$(touch pwned)",
+	"`touch pwned`",
+	`it's "quoted"`,
+	"with space",
+	"tab\tname",
+	"-rf",
+	"--help",
+	"*",
+	"a && b | c > d",
+	".hidden",
+}
+
+func TestTarCmdArgv(t *testing.T) {
+	for _, name := range hostileNames {
+		cmd := tarCreateCmd("/tmp/dir with space", name, true)
+		if cmd[0] != "tar" {
+			t.Fatalf("tarCreateCmd(%q) runs %q, want tar", name, cmd[0])
+		}
+		if got := cmd[len(cmd)-1]; got != name || cmd[len(cmd)-2] != "--" {
+			t.Errorf("tarCreateCmd(%q) = %q, want the name as the only operand after --", name, cmd)
+		}
+	}
+}
+
+func TestChmodChownCmd(t *testing.T) {
+	tests := []struct {
+		cmd  []string
+		want string
+	}{
+		{cmd: chmodCmd(0o644, "/etc/app.conf", false), want: "chmod 644 -- /etc/app.conf"},
+		{cmd: chmodCmd(fs.ModeSetgid|fs.ModeSticky|0o775, "/data", true), want: "chmod -R 3775 -- /data"},
+		{cmd: chownCmd("nginx", "www", "/data", true), want: "chown -R nginx:www -- /data"},
+		{cmd: chownCmd("1000", "", "/data", false), want: "chown 1000 -- /data"},
+		{cmd: chownCmd("", "www", "/data", false), want: "chown :www -- /data"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.cmd, " "); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// TestTarRoundTrip downloads and uploads hostile names with the local tar,
+// checking that every file arrives intact and that nothing else runs.
+func TestTarRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not available")
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	for _, name := range hostileNames {
+		t.Run(name, func(t *testing.T) {
+			content := []byte("content of " + name)
+			if err := os.WriteFile(filepath.Join(src, name), content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			archive := new(bytes.Buffer)
+			cmd := tarCreateCmd(src, name, true)
+			create := exec.Command(cmd[0], cmd[1:]...)
+			create.Dir = t.TempDir()
+			create.Stdout = archive
+			if err := create.Run(); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+
+			// downloads are gzipped, uploads are plain tarballs
+			zr, err := gzip.NewReader(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd = tarExtractCmd(dst)
+			extract := exec.Command(cmd[0], cmd[1:]...)
+			extract.Dir = t.TempDir()
+			extract.Stdin = zr
+			if out, err := extract.CombinedOutput(); err != nil {
+				t.Fatalf("extract: %v: %s", err, out)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dst, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("content = %q, want %q", got, content)
+			}
+		})
+	}
+
+	for _, dir := range []string{src, dst} {
+		if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+			t.Errorf("command injection: %s/pwned exists", dir)
+		}
+	}
+}
+
+// TestListHostileNames lists hostile names with the local find and stat.
+func TestListHostileNames(t *testing.T) {
+	if _, err := exec.LookPath("find"); err != nil {
+		t.Skip("find not available")
+	}
+	dir := t.TempDir()
+	for _, name := range hostileNames {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := listCmd(dir + "/")
+	out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, f := range parseStatList(string(out)) {
+		got[f.Name] = true
+	}
+	for _, name := range hostileNames {
+		if !got[name] {
+			t.Errorf("%q is missing from the listing %v", name, got)
+		}
+	}
+	if len(got) != len(hostileNames) {
+		t.Errorf("listing has %d entries, want %d", len(got), len(hostileNames))
+	}
+}
+
+// TestRangeCmd reads ranges of hostile names with the local tail and head.
+func TestRangeCmd(t *testing.T) {
+	if _, err := exec.LookPath("tail"); err != nil {
+		t.Skip("tail not available")
+	}
+	dir := t.TempDir()
+	for _, name := range hostileNames {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			if err := os.WriteFile(file, []byte("0123456789"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := rangeCmd(file, 3, 4)
+			out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != "3456" {
+				t.Errorf("rangeCmd() wrote %q, want %q", out, "3456")
+			}
+		})
+	}
+}
+
+func TestRangeWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rw := &rangeWriter{w: buf, skip: 3, left: 4}
+	for _, chunk := range []string{"01", "234", "5678", "9"} {
+		if _, err := rw.Write([]byte(chunk)); err != nil {
+			if err != errRangeDone {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if buf.String() != "3456" || rw.left != 0 {
+		t.Errorf("rangeWriter wrote %q, %d left", buf, rw.left)
+	}
+}
@@ -2,9 +2,14 @@ package models
 
 import (
 	"errors"
+	"io/fs"
 	"strings"
 )
 
+type Cluster struct {
+	Cluster string `json:"cluster"`
+}
+
 type Namespace struct {
 	Namespace string `json:"namespace"`
 }
@@ -17,22 +22,160 @@ type Container struct {
 	Container string `json:"container"`
 }
 
+// PodInfo describes a pod, for the rules depending on it.
+type PodInfo struct {
+	Labels map[string]string `json:"labels"`
+	// SecretMounts are where the secrets and service account tokens are mounted in the container
+	SecretMounts []string `json:"secretMounts"`
+}
+
+// Identity is who uses podFiles, as authenticated.
+type Identity struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	// Admin may manage podFiles itself, it is not a Kubernetes group
+	Admin bool `json:"admin,omitempty"`
+	// Local is set for the accounts of podFiles itself, they are impersonated apart from the cluster users
+	Local bool `json:"local,omitempty"`
+}
+
+// Target addresses a container.
+type Target struct {
+	// Cluster is empty for the default cluster
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	// Identity is who accesses the container, nil for anonymous sessions
+	Identity *Identity `json:"-"`
+}
+
+// Capabilities describes the tools available in a container.
+type Capabilities struct {
+	Tools   []string `json:"tools"`
+	Missing []string `json:"missing"`
+	// Unsupported lists the operations the container can't do
+	Unsupported []string `json:"unsupported"`
+	// Debug is set when some operations go through a debug container
+	Debug bool `json:"debug"`
+}
+
 type BreadcrumbItem struct {
 	Label string `json:"label"`
+	// Href links to the page of the item, empty for the current one
+	Href string `json:"href,omitempty"`
+}
+
+// File types reported in FileInfo.Type
+const (
+	FileTypeDir    = "dir"
+	FileTypeFile   = "file"
+	FileTypeLink   = "link"
+	FileTypeChar   = "char"
+	FileTypeBlock  = "block"
+	FileTypeSocket = "socket"
+	FileTypeFifo   = "fifo"
+)
+
+// FileType returns the file type of mode.
+func FileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return FileTypeDir
+	case mode&fs.ModeSymlink != 0:
+		return FileTypeLink
+	case mode&fs.ModeCharDevice != 0:
+		return FileTypeChar
+	case mode&fs.ModeDevice != 0:
+		return FileTypeBlock
+	case mode&fs.ModeSocket != 0:
+		return FileTypeSocket
+	case mode&fs.ModeNamedPipe != 0:
+		return FileTypeFifo
+	default:
+		return FileTypeFile
+	}
+}
+
+// Perm returns mode as printed by ls -l, such as drwxr-sr-x.
+func Perm(mode fs.FileMode) string {
+	b := []byte("?rwxrwxrwx")
+	switch FileType(mode) {
+	case FileTypeDir:
+		b[0] = 'd'
+	case FileTypeLink:
+		b[0] = 'l'
+	case FileTypeChar:
+		b[0] = 'c'
+	case FileTypeBlock:
+		b[0] = 'b'
+	case FileTypeSocket:
+		b[0] = 's'
+	case FileTypeFifo:
+		b[0] = 'p'
+	default:
+		b[0] = '-'
+	}
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) == 0 {
+			b[i+1] = '-'
+		}
+	}
+	special := func(i int, set bool, c byte) {
+		if !set {
+			return
+		}
+		if b[i] == '-' {
+			c -= 'a' - 'A'
+		}
+		b[i] = c
+	}
+	special(3, mode&fs.ModeSetuid != 0, 's')
+	special(6, mode&fs.ModeSetgid != 0, 's')
+	special(9, mode&fs.ModeSticky != 0, 't')
+	return string(b)
 }
 
 type FileInfo struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Size string `json:"size"`
-	Time string `json:"time"`
+	Name string      `json:"name"`
+	Type string      `json:"type"`
+	Size int64       `json:"size"` // in bytes
+	Time int64       `json:"time"` // modification time in unix seconds
+	Mode fs.FileMode `json:"mode"`
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+	// Owner and Group are the names of UID and GID, empty if the container has none
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	// Perm is the mode as printed by ls -l, such as drwxr-xr-x
+	Perm       string `json:"perm"`
+	LinkTarget string `json:"linkTarget,omitempty"`
+	LinkDir    bool   `json:"linkDir,omitempty"` // whether the symlink points to a directory
+}
+
+// Downloadable reports whether the file can be archived for download,
+// special files such as devices, sockets and pipes can not.
+func (f *FileInfo) Downloadable() bool {
+	return f.Type == FileTypeDir || f.Type == FileTypeFile
 }
 
 type State struct {
+	Cluster   string   `json:"cluster"`
 	Namespace string   `json:"namespace"`
 	Pod       string   `json:"pod"`
 	Container string   `json:"container"`
 	Path      []string `json:"path"`
+	// ShowHidden lists dotfiles, it is kept across containers
+	ShowHidden bool `json:"showHidden"`
+	// Identity is set once the session is authenticated
+	Identity *Identity `json:"identity,omitempty"`
+	// Account is set when Identity is a local account, the session ends with it
+	Account bool `json:"account,omitempty"`
+}
+
+func (s *State) SetCluster(cluster string) {
+	s.Cluster = cluster
+	s.SetNamespace("")
 }
 
 func (s *State) SetNamespace(namespace string) {
@@ -50,6 +193,10 @@ func (s *State) SetContainer(container string) {
 	s.SetPath(nil)
 }
 
+func (s *State) SetShowHidden(show bool) {
+	s.ShowHidden = show
+}
+
 func (s *State) SetPath(path []string) {
 	s.Path = path
 }
@@ -66,6 +213,10 @@ func (s *State) PopPath() error {
 	return nil
 }
 
+func (s *State) Target() Target {
+	return Target{Cluster: s.Cluster, Namespace: s.Namespace, Pod: s.Pod, Container: s.Container, Identity: s.Identity}
+}
+
 func (s *State) FSPath() string {
 	return "/" + strings.Join(s.Path, "/")
 }
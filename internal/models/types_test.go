@@ -1,6 +1,7 @@
 package models
 
 import (
+	"io/fs"
 	"testing"
 )
 
@@ -47,3 +48,26 @@ func TestState_FSPath(t *testing.T) {
 		})
 	}
 }
+
+func TestPerm(t *testing.T) {
+	tests := []struct {
+		mode fs.FileMode
+		want string
+	}{
+		{mode: 0o644, want: "-rw-r--r--"},
+		{mode: fs.ModeDir | 0o755, want: "drwxr-xr-x"},
+		{mode: fs.ModeSymlink | 0o777, want: "lrwxrwxrwx"},
+		{mode: fs.ModeSetuid | 0o755, want: "-rwsr-xr-x"},
+		{mode: fs.ModeDir | fs.ModeSetgid | 0o750, want: "drwxr-s---"},
+		{mode: fs.ModeDir | fs.ModeSticky | 0o777, want: "drwxrwxrwt"},
+		{mode: fs.ModeSetuid | fs.ModeSticky | 0o644, want: "-rwSr--r-T"},
+		{mode: fs.ModeDevice | fs.ModeCharDevice | 0o666, want: "crw-rw-rw-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := Perm(tt.mode); got != tt.want {
+				t.Errorf("Perm(%v) = %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Package oidc logs users in with an OpenID Connect provider, by the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/state"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// loginTimeout is how long a user may take at the provider
+	loginTimeout = 10 * time.Minute
+	// clockSkew is tolerated when checking the token times
+	clockSkew = time.Minute
+	// pendingPrefix keeps the pending logins apart from the sessions in the store
+	pendingPrefix = "oidc-login:"
+)
+
+// ErrState reports a callback without a pending login, expired or forged.
+var ErrState = errors.New("unknown or expired login state")
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// UserClaim is the claim naming the user, such as email or preferred_username
+	UserClaim string
+	// GroupsClaim is the claim listing the groups of the user
+	GroupsClaim string
+	// Store keeps the pending logins, so that any replica completes them, in memory if nil
+	Store state.SessionStore
+}
+
+// pendingLogin is what a callback needs from the login it completes.
+type pendingLogin struct {
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirectURL"`
+}
+
+// Provider is an OpenID Connect provider, it is safe for concurrent use.
+type Provider struct {
+	cfg      Config
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+	client   *http.Client
+
+	pending state.SessionStore
+}
+
+// NewProvider discovers the provider at cfg.Issuer.
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	p := &Provider{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		pending: cfg.Store,
+	}
+	if p.pending == nil {
+		p.pending = state.NewMemoryStore()
+	}
+	// the provider fetches its signing keys with the client of the discovery
+	provider, err := gooidc.NewProvider(gooidc.ClientContext(ctx, p.client), cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	p.verifier = provider.Verifier(&gooidc.Config{
+		ClientID: cfg.ClientID,
+		Now:      func() time.Time { return time.Now().Add(-clockSkew) },
+	})
+	p.oauth = oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Scopes:       cfg.Scopes,
+		Endpoint:     provider.Endpoint(),
+	}
+	return p, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL starts a login, the provider redirects to redirectURL afterwards.
+// The returned state must come back with the callback, from the same browser.
+func (p *Provider) AuthCodeURL(redirectURL string) (url, state string, err error) {
+	state, err = randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+	b, err := json.Marshal(pendingLogin{Nonce: nonce, Verifier: verifier, RedirectURL: redirectURL})
+	if err != nil {
+		return "", "", err
+	}
+	if err := p.pending.Set(pendingPrefix+state, b, loginTimeout); err != nil {
+		return "", "", fmt.Errorf("keep the pending login: %w", err)
+	}
+
+	oc := p.oauth
+	oc.RedirectURL = redirectURL
+	url = oc.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce))
+	return url, state, nil
+}
+
+// Exchange completes the login of state with the authorization code and returns the identity of the user.
+func (p *Provider) Exchange(ctx context.Context, state, code string) (*models.Identity, error) {
+	b, err := p.pending.Get(pendingPrefix + state)
+	if err != nil {
+		return nil, fmt.Errorf("get the pending login: %w", err)
+	}
+	if b == nil {
+		return nil, ErrState
+	}
+	// a login completes once
+	if err := p.pending.Remove(pendingPrefix + state); err != nil {
+		return nil, fmt.Errorf("remove the pending login: %w", err)
+	}
+	var pl pendingLogin
+	if err := json.Unmarshal(b, &pl); err != nil {
+		return nil, fmt.Errorf("decode the pending login: %w", err)
+	}
+
+	oc := p.oauth
+	oc.RedirectURL = pl.RedirectURL
+	token, err := oc.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.client), code, oauth2.VerifierOption(pl.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("oidc token response without id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id token: %w", err)
+	}
+	if idToken.Nonce != pl.Nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("id token claims: %w", err)
+	}
+	return p.identity(claims)
+}
+
+// stringList reads a claim that is a string or a list of strings.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+func (p *Provider) identity(claims map[string]any) (*models.Identity, error) {
+	user, _ := claims[p.cfg.UserClaim].(string)
+	if user == "" {
+		return nil, fmt.Errorf("id token without the %s claim", p.cfg.UserClaim)
+	}
+	return &models.Identity{User: user, Groups: stringList(claims[p.cfg.GroupsClaim])}, nil
+}
@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/state"
+)
+
+// mockIssuer is an OpenID Connect provider issuing the id tokens its claims func returns.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string
+	nonce     string
+	claims    func(nonce string) map[string]any
+	// signer signs the id tokens, the key of the issuer by default
+	signer *rsa.PrivateKey
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		signer := m.signer
+		if signer == nil {
+			signer = m.key
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     signRS256(t, signer, "k1", m.claims(m.nonce)),
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// authorize plays the user logging in at the provider.
+func (m *mockIssuer) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+	m.mu.Lock()
+	m.challenge, m.nonce = q.Get("code_challenge"), q.Get("nonce")
+	m.mu.Unlock()
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	hdr, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestProvider(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+	// shared by the replicas
+	store, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		Issuer:      m.URL,
+		ClientID:    "podfiles",
+		Scopes:      []string{"openid", "email", "groups"},
+		UserClaim:   "email",
+		GroupsClaim: "groups",
+		Store:       store,
+	}
+	p, err := NewProvider(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, err := NewProvider(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func(nonce string) map[string]any {
+		return map[string]any{
+			"iss":    m.URL,
+			"aud":    "podfiles",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"nonce":  nonce,
+			"email":  "alice@example.com",
+			"groups": []string{"qa", "sre"},
+		}
+	}
+	with := func(key string, value any) func(string) map[string]any {
+		return func(nonce string) map[string]any {
+			c := valid(nonce)
+			c[key] = value
+			return c
+		}
+	}
+
+	tests := []struct {
+		name    string
+		claims  func(nonce string) map[string]any
+		signer  *rsa.PrivateKey
+		code    string
+		wantErr string
+	}{
+		{name: "valid", claims: valid},
+		{name: "audience list", claims: with("aud", []string{"other", "podfiles"})},
+		{name: "other audience", claims: with("aud", "other"), wantErr: "audience"},
+		{name: "other issuer", claims: with("iss", "https://evil.example.com"), wantErr: "different provider"},
+		{name: "expired", claims: with("exp", time.Now().Add(-time.Hour).Unix()), wantErr: "expired"},
+		{name: "replayed nonce", claims: with("nonce", "old"), wantErr: "nonce"},
+		{name: "no user", claims: with("email", ""), wantErr: "email"},
+		{name: "forged signature", claims: valid, signer: otherKey, wantErr: "signature"},
+		{name: "bad code", claims: valid, code: "bad-code", wantErr: "invalid_grant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.mu.Lock()
+			m.claims, m.signer = tt.claims, tt.signer
+			m.mu.Unlock()
+
+			authURL, state, err := p.AuthCodeURL("http://podfiles.example.com/api/oidc/callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.authorize(t, authURL)
+			code := tt.code
+			if code == "" {
+				code = "good-code"
+			}
+			id, err := p.Exchange(ctx, state, code)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange() error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id.User != "alice@example.com" || !slices.Equal(id.Groups, []string{"qa", "sre"}) {
+				t.Errorf("identity = %+v, want alice@example.com in qa and sre", id)
+			}
+
+			// the state is single use
+			if _, err := p.Exchange(ctx, state, code); !errors.Is(err, ErrState) {
+				t.Errorf("second Exchange() error = %v, want %v", err, ErrState)
+			}
+		})
+	}
+
+	if _, err := p.Exchange(ctx, "forged", "good-code"); !errors.Is(err, ErrState) {
+		t.Errorf("Exchange() of an unknown state = %v, want %v", err, ErrState)
+	}
+
+	// another replica completes the login
+	m.mu.Lock()
+	m.claims, m.signer = valid, nil
+	m.mu.Unlock()
+	authURL, loginState, err := p.AuthCodeURL("http://podfiles.example.com/api/oidc/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.authorize(t, authURL)
+	if id, err := replica.Exchange(ctx, loginState, "good-code"); err != nil || id.User != "alice@example.com" {
+		t.Errorf("Exchange() on another replica = %+v, %v", id, err)
+	}
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DefaultDeny are the paths denied in every container, on top of its secret mounts.
+var DefaultDeny = []string{
+	"/var/run/secrets",
+	"/run/secrets",
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/proc",
+}
+
+// PathRule denies the paths matching Deny, and allows again the ones matching Allow,
+// in the pods it selects. A glob matching a directory covers everything in it.
+type PathRule struct {
+	// Namespaces lists the namespace globs, all namespaces if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector is a label selector of the pods, such as "app=db,tier!=test", all pods if empty
+	Selector string   `json:"selector,omitempty"`
+	Deny     []string `json:"deny,omitempty"`
+	Allow    []string `json:"allow,omitempty"`
+
+	selector labels.Selector
+}
+
+func (r *PathRule) compile() error {
+	for _, glob := range r.Namespaces {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("%q: %w", glob, err)
+		}
+	}
+	for _, glob := range slices.Concat(r.Deny, r.Allow) {
+		if !path.IsAbs(glob) {
+			return fmt.Errorf("%q: path globs are absolute", glob)
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("%q: %w", glob, err)
+		}
+	}
+	sel, err := labels.Parse(r.Selector)
+	if err != nil {
+		return err
+	}
+	r.selector = sel
+	return nil
+}
+
+func (r *PathRule) selects(namespace string, podLabels map[string]string) bool {
+	if len(r.Namespaces) > 0 && !matchAny(r.Namespaces, namespace) {
+		return false
+	}
+	return r.selector == nil || r.selector.Matches(labels.Set(podLabels))
+}
+
+// PathFilter tells the denied paths of a container.
+type PathFilter struct {
+	deny  []string
+	allow []string
+}
+
+// Paths returns the path filter of a pod in namespace with podLabels,
+// denying the default paths, the secretMounts and the rules selecting the pod.
+func (p *Policy) Paths(namespace string, podLabels map[string]string, secretMounts []string) *PathFilter {
+	f := &PathFilter{deny: slices.Concat(DefaultDeny, secretMounts)}
+	if p == nil {
+		return f
+	}
+	for i := range p.PathRules {
+		r := &p.PathRules[i]
+		if r.selects(namespace, podLabels) {
+			f.deny = append(f.deny, r.Deny...)
+			f.allow = append(f.allow, r.Allow...)
+		}
+	}
+	return f
+}
+
+// Denied reports whether the absolute path p is denied.
+func (f *PathFilter) Denied(p string) bool {
+	p = path.Clean(p)
+	return covered(f.deny, p) && !covered(f.allow, p)
+}
+
+// Contains reports whether the directory dir holds denied paths, so that it can't be archived, removed or moved whole.
+func (f *PathFilter) Contains(dir string) bool {
+	dir = path.Clean(dir)
+	if covered(f.allow, dir) {
+		return false
+	}
+	for _, glob := range f.deny {
+		if below(glob, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// covered reports whether p or one of its parents matches one of globs.
+func covered(globs []string, p string) bool {
+	for {
+		if matchAny(globs, p) {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+// below reports whether glob may match paths below dir.
+func below(glob, dir string) bool {
+	globParts := split(glob)
+	dirParts := split(dir)
+	if len(globParts) <= len(dirParts) {
+		return false
+	}
+	for i, d := range dirParts {
+		if ok, _ := path.Match(globParts[i], d); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func split(p string) []string {
+	p = strings.Trim(path.Clean(p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,171 @@
+// Package policy decides what the users may do in which namespaces,
+// by rules mapping users and groups to namespaces and an access level,
+// and which paths of the containers are out of reach.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/zrcoder/podFiles/internal/models"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Access is what a user may do in a namespace, each level includes the lower ones.
+type Access int
+
+const (
+	None Access = iota
+	// Read lists and downloads the files
+	Read
+	// Write uploads and changes the files too
+	Write
+	// Admin manages podFiles as well, such as its users
+	Admin
+)
+
+var accessNames = []string{"none", "read-only", "read-write", "admin"}
+
+func (a Access) String() string {
+	if a < None || int(a) >= len(accessNames) {
+		return fmt.Sprintf("Access(%d)", int(a))
+	}
+	return accessNames[a]
+}
+
+func (a Access) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Access) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	i := slices.Index(accessNames, s)
+	if i < 0 {
+		return fmt.Errorf("unknown access %q, want one of %q", s, accessNames)
+	}
+	*a = Access(i)
+	return nil
+}
+
+// Rule grants Access in Namespaces to Users and the members of Groups.
+type Rule struct {
+	// Users lists the user names, "*" is anyone
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Namespaces lists the namespace globs, all namespaces if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Except lists the namespace globs the rule doesn't apply to
+	Except []string `json:"except,omitempty"`
+	Access Access   `json:"access"`
+}
+
+// Policy is a set of rules, the most permissive matching rule wins and no rule means no access.
+// A nil Policy, or one without Rules, lets everyone read and write, as podFiles does without a policy file.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+	// PathRules deny paths, on top of DefaultDeny
+	PathRules []PathRule `json:"paths,omitempty"`
+}
+
+// Load reads the policy in the YAML or JSON file at p.
+func Load(p string) (*Policy, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var pol Policy
+	if err := yaml.UnmarshalStrict(data, &pol); err != nil {
+		return nil, fmt.Errorf("policy %s: %w", p, err)
+	}
+	for i, r := range pol.Rules {
+		if len(r.Users) == 0 && len(r.Groups) == 0 {
+			return nil, fmt.Errorf("policy %s: rule %d applies to no users or groups", p, i)
+		}
+		for _, glob := range slices.Concat(r.Namespaces, r.Except) {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("policy %s: rule %d: %q: %w", p, i, glob, err)
+			}
+		}
+	}
+	for i := range pol.PathRules {
+		if err := pol.PathRules[i].compile(); err != nil {
+			return nil, fmt.Errorf("policy %s: path rule %d: %w", p, i, err)
+		}
+	}
+	return &pol, nil
+}
+
+// Access returns the access of id to namespace, id is nil for anonymous sessions.
+func (p *Policy) Access(id *models.Identity, namespace string) Access {
+	if id != nil && id.Admin {
+		return Admin
+	}
+	if p == nil || len(p.Rules) == 0 {
+		return Write
+	}
+	access := None
+	for _, r := range p.Rules {
+		if r.Access > access && r.appliesTo(id) && r.covers(namespace) {
+			access = r.Access
+		}
+	}
+	return access
+}
+
+// IsAdmin reports whether id may manage podFiles, admin accounts and the users of admin rules
+// covering all namespaces. An admin rule scoped by namespaces grants admin in those only.
+func (p *Policy) IsAdmin(id *models.Identity) bool {
+	if id != nil && id.Admin {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	for _, r := range p.Rules {
+		if r.Access == Admin && len(r.Namespaces) == 0 && len(r.Except) == 0 && r.appliesTo(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) appliesTo(id *models.Identity) bool {
+	if slices.Contains(r.Users, "*") {
+		return true
+	}
+	if id == nil {
+		return false
+	}
+	if slices.Contains(r.Users, id.User) {
+		return true
+	}
+	for _, g := range id.Groups {
+		if slices.Contains(r.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) covers(namespace string) bool {
+	if len(r.Namespaces) > 0 && !matchAny(r.Namespaces, namespace) {
+		return false
+	}
+	return !matchAny(r.Except, namespace)
+}
+
+func matchAny(globs []string, name string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zrcoder/podFiles/internal/models"
+)
+
+const testPolicy = `
+rules:
+  - groups: [qa]
+    namespaces: ["staging-*"]
+    access: read-only
+  - groups: [sre]
+    except: ["kube-*"]
+    access: read-write
+  - users: [carol]
+    access: admin
+  - users: [erin]
+    namespaces: ["team-*"]
+    access: admin
+  - users: ["*"]
+    namespaces: [public]
+    access: read-only
+`
+
+func TestAccess(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(file, []byte(testPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qa := &models.Identity{User: "alice", Groups: []string{"qa"}}
+	sre := &models.Identity{User: "bob", Groups: []string{"dev", "sre"}}
+	tests := []struct {
+		name      string
+		policy    *Policy
+		id        *models.Identity
+		namespace string
+		want      Access
+	}{
+		{name: "qa in staging", policy: p, id: qa, namespace: "staging-eu", want: Read},
+		{name: "qa in prod", policy: p, id: qa, namespace: "prod", want: None},
+		{name: "sre in prod", policy: p, id: sre, namespace: "prod", want: Write},
+		{name: "sre in kube-system", policy: p, id: sre, namespace: "kube-system", want: None},
+		{name: "most permissive rule", policy: p, id: &models.Identity{User: "dave", Groups: []string{"qa", "sre"}}, namespace: "staging-eu", want: Write},
+		{name: "admin rule", policy: p, id: &models.Identity{User: "carol"}, namespace: "kube-system", want: Admin},
+		{name: "scoped admin rule", policy: p, id: &models.Identity{User: "erin"}, namespace: "team-a", want: Admin},
+		{name: "out of scoped admin rule", policy: p, id: &models.Identity{User: "erin"}, namespace: "prod", want: None},
+		{name: "anyone", policy: p, namespace: "public", want: Read},
+		{name: "anonymous", policy: p, namespace: "prod", want: None},
+		{name: "admin account", policy: p, id: &models.Identity{User: "admin", Admin: true}, namespace: "prod", want: Admin},
+		{name: "no policy", id: qa, namespace: "prod", want: Write},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Access(tt.id, tt.namespace); got != tt.want {
+				t.Errorf("Access() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !p.IsAdmin(&models.Identity{User: "carol"}) || p.IsAdmin(sre) {
+		t.Error("IsAdmin() does not follow the admin rules")
+	}
+	if p.IsAdmin(&models.Identity{User: "erin"}) {
+		t.Error("IsAdmin() follows an admin rule scoped by namespaces")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{name: "unknown access", policy: `{"rules": [{"users": ["alice"], "access": "root"}]}`},
+		{name: "no subject", policy: `{"rules": [{"access": "read-only"}]}`},
+		{name: "bad glob", policy: `{"rules": [{"users": ["alice"], "namespaces": ["["], "access": "read-only"}]}`},
+		{name: "unknown field", policy: `{"rules": [{"user": ["alice"], "access": "read-only"}]}`},
+		{name: "relative path", policy: `{"paths": [{"deny": ["etc/shadow"]}]}`},
+		{name: "bad selector", policy: `{"paths": [{"selector": "app in (", "deny": ["/data"]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(file, []byte(tt.policy), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(file); err == nil {
+				t.Error("Load() accepted an invalid policy")
+			}
+		})
+	}
+}
+
+func TestPaths(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	err := os.WriteFile(file, []byte(`
+paths:
+  - deny: ["/home/*/.ssh"]
+  - namespaces: ["prod-*"]
+    selector: "app=db"
+    deny: ["/data"]
+    allow: ["/data/public"]
+  - allow: ["/proc/self/status"]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Access(&models.Identity{User: "alice"}, "prod-eu") != Write {
+		t.Error("a policy without access rules denies access")
+	}
+
+	db := p.Paths("prod-eu", map[string]string{"app": "db"}, []string{"/etc/certs"})
+	web := p.Paths("prod-eu", map[string]string{"app": "web"}, nil)
+	tests := []struct {
+		name   string
+		filter *PathFilter
+		path   string
+		want   bool
+	}{
+		{name: "service account token", filter: web, path: "/var/run/secrets/kubernetes.io/serviceaccount/token", want: true},
+		{name: "shadow", filter: web, path: "/etc/shadow", want: true},
+		{name: "passwd", filter: web, path: "/etc/passwd"},
+		{name: "proc", filter: web, path: "/proc/1/environ", want: true},
+		{name: "allowed again", filter: web, path: "/proc/self/status"},
+		{name: "secret mount", filter: db, path: "/etc/certs/tls.key", want: true},
+		{name: "other pod's secret mount", filter: web, path: "/etc/certs/tls.key"},
+		{name: "glob", filter: web, path: "/home/alice/.ssh/id_rsa", want: true},
+		{name: "selected pod", filter: db, path: "/data/db.sqlite", want: true},
+		{name: "selected pod, allowed", filter: db, path: "/data/public/index.html"},
+		{name: "other pod", filter: web, path: "/data/db.sqlite"},
+		{name: "unclean", filter: web, path: "/etc/../etc/shadow", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Denied(tt.path); got != tt.want {
+				t.Errorf("Denied(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for dir, want := range map[string]bool{"/": true, "/etc": true, "/home/bob": true, "/data/public": false, "/usr": false} {
+		if got := db.Contains(dir); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", dir, got, want)
+		}
+	}
+	if !(*Policy)(nil).Paths("default", nil, nil).Denied("/var/run/secrets/kubernetes.io/serviceaccount/token") {
+		t.Error("the defaults don't apply without a policy")
+	}
+}
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/util/log"
+)
+
+const (
+	sessionFileExt = ".json"
+	sweepInterval  = 5 * time.Minute
+)
+
+// FileStore keeps each session in a file of a directory, which the replicas may share as a volume.
+type FileStore struct {
+	dir string
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+type fileEntry struct {
+	Expires int64           `json:"expires"`
+	State   json.RawMessage `json:"state"`
+}
+
+// NewFileStore keeps the sessions in dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, lastSweep: time.Now()}, nil
+}
+
+// file returns the file of session, named by its hash so that session ids never make paths.
+func (f *FileStore) file(session string) string {
+	sum := sha256.Sum256([]byte(session))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+sessionFileExt)
+}
+
+func (f *FileStore) Get(session string) ([]byte, error) {
+	e, err := readEntry(f.file(session))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() >= e.Expires {
+		return nil, f.Remove(session)
+	}
+	return e.State, nil
+}
+
+func readEntry(name string) (*fileEntry, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var e fileEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (f *FileStore) Set(session string, state []byte, ttl time.Duration) error {
+	b, err := json.Marshal(fileEntry{Expires: time.Now().Add(ttl).Unix(), State: state})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.file(session)); err != nil {
+		return err
+	}
+	f.maybeSweep()
+	return nil
+}
+
+func (f *FileStore) Remove(session string) error {
+	err := os.Remove(f.file(session))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// maybeSweep removes the expired sessions once in a while, in the background.
+func (f *FileStore) maybeSweep() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.lastSweep) < sweepInterval {
+		return
+	}
+	f.lastSweep = time.Now()
+	go f.sweep()
+}
+
+func (f *FileStore) sweep() {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		slog.Error("sweep sessions", log.Error(err))
+		return
+	}
+	now := time.Now().Unix()
+	for _, de := range entries {
+		if !strings.HasSuffix(de.Name(), sessionFileExt) {
+			continue
+		}
+		name := filepath.Join(f.dir, de.Name())
+		if e, err := readEntry(name); err == nil && now >= e.Expires {
+			os.Remove(name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package state
+
+import (
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// MemoryStore keeps the sessions in the process, they are lost on restart and not shared by replicas.
+type MemoryStore struct {
+	cache *cache.Cache
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{cache: cache.New(sessionLife, 5*time.Minute)}
+}
+
+func (m *MemoryStore) Get(session string) ([]byte, error) {
+	v, ok := m.cache.Get(session)
+	if !ok {
+		return nil, nil
+	}
+	return v.([]byte), nil
+}
+
+func (m *MemoryStore) Set(session string, state []byte, ttl time.Duration) error {
+	m.cache.Set(session, state, ttl)
+	return nil
+}
+
+func (m *MemoryStore) Remove(session string) error {
+	m.cache.Delete(session)
+	return nil
+}
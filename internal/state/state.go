@@ -1,36 +1,95 @@
+// Package state keeps the navigation state of the sessions in a SessionStore,
+// in memory by default, or shared by the replicas in files or Redis.
 package state
 
 import (
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/zrcoder/podFiles/internal/models"
-
-	"github.com/patrickmn/go-cache"
+	"github.com/zrcoder/podFiles/internal/util/log"
 )
 
 const (
-	SessionKey     = "podfiles_session"
+	SessionKey = "podfiles_session"
+	// StateKey is where auth.Auth puts the state of the session in the gin context
+	StateKey       = "podfiles_state"
 	SessionMinutes = 30
 	sessionLife    = SessionMinutes * time.Minute
+
+	// maxStateSize bounds what is decoded from a store
+	maxStateSize = 64 << 10
 )
 
-var sessins = cache.New(sessionLife, 5*time.Minute)
+// SessionStore keeps the encoded states of the sessions, it is safe for concurrent use.
+type SessionStore interface {
+	// Get returns the state of session, or nil if it is unknown or expired.
+	Get(session string) ([]byte, error)
+	// Set keeps the state of session for ttl.
+	Set(session string, state []byte, ttl time.Duration) error
+	// Remove forgets session.
+	Remove(session string) error
+}
 
-func Add(session string) {
+var store SessionStore = NewMemoryStore()
+
+// Use keeps the sessions in s from now on.
+func Use(s SessionStore) {
+	store = s
+}
+
+// Add starts session with an empty state.
+func Add(session string) (*models.State, error) {
 	slog.Debug("add session", slog.String("session", session))
-	sessins.Add(session, &models.State{}, sessionLife)
+	st := &models.State{}
+	return st, Save(session, st)
 }
 
+// Get returns a copy of the state of session, or nil if it is unknown or expired.
+// Its changes are kept by Save.
 func Get(session string) *models.State {
 	slog.Debug("get session", slog.String("session", session))
-	s, ok := sessins.Get(session)
-	if !ok {
+	b, err := store.Get(session)
+	if err != nil {
+		slog.Error("get session", log.Error(err))
+		return nil
+	}
+	if b == nil {
+		return nil
+	}
+	st, err := decode(b)
+	if err != nil {
+		// a corrupted or foreign entry is no session
+		slog.Warn("decode session", log.Error(err))
 		return nil
 	}
-	return s.(*models.State)
+	return st
+}
+
+// Save keeps st as the state of session, for another session life.
+func Save(session string, st *models.State) error {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return store.Set(session, b, sessionLife)
 }
 
 func Remove(session string) {
-	sessins.Delete(session)
+	if err := store.Remove(session); err != nil {
+		slog.Error("remove session", log.Error(err))
+	}
+}
+
+func decode(b []byte) (*models.State, error) {
+	if len(b) > maxStateSize {
+		return nil, errors.New("session state too large")
+	}
+	st := &models.State{}
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, err
+	}
+	return st, nil
 }
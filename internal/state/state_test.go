@@ -0,0 +1,182 @@
+package state
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	redis, err := NewRedisStore("redis://user:secret@" + fakeRedis(t, "user", "secret") + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := NewRedisStore("redis://user:wrong@" + redis.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Get("a"); err == nil {
+		t.Error("Get() with a wrong password succeeded")
+	}
+	stores := []struct {
+		name  string
+		store SessionStore
+	}{
+		{"memory", NewMemoryStore()},
+		{"file", file},
+		{"redis", redis},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.store
+			if b, err := s.Get("unknown"); err != nil || b != nil {
+				t.Fatalf("Get(unknown) = %q, %v, want nil", b, err)
+			}
+			if err := s.Set("a", []byte(`{"cluster":"c1"}`), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if b, err := s.Get("a"); err != nil || string(b) != `{"cluster":"c1"}` {
+				t.Fatalf("Get(a) = %q, %v", b, err)
+			}
+			if err := s.Remove("a"); err != nil {
+				t.Fatal(err)
+			}
+			if b, err := s.Get("a"); err != nil || b != nil {
+				t.Fatalf("Get(a) after Remove = %q, %v, want nil", b, err)
+			}
+			if err := s.Remove("a"); err != nil {
+				t.Errorf("Remove(a) twice: %v", err)
+			}
+			if err := s.Set("b", []byte(`{}`), 10*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if b, err := s.Get("b"); err != nil || b != nil {
+				t.Errorf("Get(b) after expiry = %q, %v, want nil", b, err)
+			}
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	defer Use(store)
+	Use(NewMemoryStore())
+
+	st, err := Add("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Cluster = "c1"
+	if got := Get("s1"); got == nil || got.Cluster != "" {
+		t.Fatalf("Get(s1) = %+v, want an empty state before Save", got)
+	}
+	if err := Save("s1", st); err != nil {
+		t.Fatal(err)
+	}
+	if got := Get("s1"); got == nil || got.Cluster != "c1" {
+		t.Fatalf("Get(s1) = %+v, want cluster c1", got)
+	}
+
+	// corrupted entries are no sessions
+	store.Set("s2", []byte("{"), time.Minute)
+	if got := Get("s2"); got != nil {
+		t.Errorf("Get(s2) = %+v, want nil", got)
+	}
+	store.Set("s3", []byte(`"`+strings.Repeat("x", maxStateSize)+`"`), time.Minute)
+	if got := Get("s3"); got != nil {
+		t.Errorf("Get(s3) = %+v, want nil", got)
+	}
+
+	Remove("s1")
+	if got := Get("s1"); got != nil {
+		t.Errorf("Get(s1) after Remove = %+v, want nil", got)
+	}
+}
+
+// fakeRedis serves GET, SET with PX, DEL, AUTH and SELECT on a local port,
+// answering NOAUTH until the client authenticates as user with password.
+func fakeRedis(t *testing.T, user, password string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	type entry struct {
+		value   string
+		expires time.Time
+	}
+	var mu sync.Mutex
+	data := map[string]entry{}
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		authed := false
+		for {
+			reply, err := readReply(r)
+			if err != nil {
+				return
+			}
+			var args []string
+			for _, a := range reply.([]any) {
+				args = append(args, string(a.([]byte)))
+			}
+			var out string
+			mu.Lock()
+			switch cmd := strings.ToUpper(args[0]); {
+			case cmd == "AUTH":
+				authed = len(args) == 3 && args[1] == user && args[2] == password
+				out = "+OK\r\n"
+				if !authed {
+					out = "-WRONGPASS invalid username-password pair\r\n"
+				}
+			case !authed:
+				out = "-NOAUTH Authentication required.\r\n"
+			case cmd == "SELECT":
+				out = "+OK\r\n"
+			case cmd == "GET":
+				e, ok := data[args[1]]
+				if !ok || time.Now().After(e.expires) {
+					out = "$-1\r\n"
+				} else {
+					out = "$" + strconv.Itoa(len(e.value)) + "\r\n" + e.value + "\r\n"
+				}
+			case cmd == "SET":
+				ms, _ := strconv.Atoi(args[4])
+				data[args[1]] = entry{args[2], time.Now().Add(time.Duration(ms) * time.Millisecond)}
+				out = "+OK\r\n"
+			case cmd == "DEL":
+				_, ok := data[args[1]]
+				delete(data, args[1])
+				out = ":0\r\n"
+				if ok {
+					out = ":1\r\n"
+				}
+			default:
+				out = "-ERR unknown command\r\n"
+			}
+			mu.Unlock()
+			if _, err := conn.Write([]byte(out)); err != nil {
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return l.Addr().String()
+}
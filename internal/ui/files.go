@@ -1,58 +1,226 @@
 package ui
 
 import (
+	"fmt"
+
 	"github.com/zrcoder/amisgo"
 	"github.com/zrcoder/amisgo/comp"
 	"github.com/zrcoder/podFiles/internal/api"
+	"github.com/zrcoder/podFiles/internal/auth"
 )
 
+// FileList lists a directory of the container in the query of the page,
+// with the cluster, namespace, pod, container, path and hidden parameters.
 func FileList(app *amisgo.App) comp.Page {
 	return page(
 		app,
-		app.Service().Name("files").Api(api.Files).Body(
+		app.Service().Name("files").Api(containerApi(api.V2Files, "path")+"&hidden=${hidden}").Body(
 			app.Flex().ClassName("h-10").AlignItems("center").Justify("flex-start").Items(
 				app.Button().Icon("fa fa-home").Label("${i18n.home}").
-					ActionType("link").Link("/").Reload("/"),
+					ActionType("link").Link("/?"+urlQuery("cluster", "cluster", "namespace", "namespace", "pod", "pod", "hidden", "showHidden")),
 				app.Wrapper(),
 				app.Breadcrumb().Source("${breadItems}"),
 				app.Wrapper(),
-				app.Button().Icon("fa fa-folder-open").Label("..").VisibleOn("${inSubDir}").
-					ActionType("ajax").Api("post:"+api.Files+"?back=true").Reload("files"),
+				app.Button().Icon("fa fa-folder-open").Label("..").VisibleOn("${parent}").
+					ActionType("link").Link(filesLink("parent", "showHidden")),
+				app.Wrapper(),
+				app.Button().Icon("fa fa-eye").Label("${i18n.podFile.showHidden}").VisibleOn("${!showHidden}").
+					ActionType("link").Link(filesLink("path", "true")),
+				app.Button().Icon("fa fa-eye-slash").Label("${i18n.podFile.hideHidden}").VisibleOn("${showHidden}").
+					ActionType("link").Link(filesLink("path", "false")),
 				app.Wrapper(),
-				app.Button().Icon("fa fa-upload").Label("${i18n.podFile.upload}").
+				app.Button().Icon("fa fa-upload").Label("${i18n.podFile.upload}").VisibleOn("${writable}").
 					ActionType("drawer").Drawer(
 					app.Drawer().Name("upload").Position("bottom").
 						Actions().
 						Body(
-							app.InputFile().Receiver(api.Upload).Drag(true).UseChunk(false),
+							app.InputFile().Receiver("post:"+containerApi(api.V2Files, "path")).Drag(true).UseChunk(false),
 							app.Flex().Justify("center").Items(
 								app.Button().Label("${i18n.podFile.done}").ActionType("reload").Target("files").Close("upload"),
 							),
 						),
 				),
+				app.Button().Icon("fa fa-folder").Label("${i18n.podFile.mkdir}").VisibleOn("${writable}").ClassName("ml-2").
+					ActionType("dialog").Reload("files").Dialog(
+					app.Dialog().Title("${i18n.podFile.mkdir}").Body(
+						app.Form().Api("post:"+containerApi(api.V2Mkdir, "dir + dirName")).Body(
+							app.InputText().Name("dirName").Label("${i18n.podFile.fileName}").Required(true),
+						),
+					),
+				),
+			),
+
+			app.Alert().Level("info").ShowIcon(true).ClassName("mt-2").VisibleOn("${capabilities.debug}").Body(
+				app.Tpl().Tpl("${i18n.podFile.debugContainer} ${i18n.podFile.missingTools}: ${capabilities.missing|join:, }"),
+			),
+			app.Alert().Level("warning").ShowIcon(true).ClassName("mt-2").VisibleOn("${capabilities.unsupported.length > 0}").Body(
+				app.Tpl().Tpl("${i18n.podFile.unsupported}: ${capabilities.unsupported|join:, }. ${i18n.podFile.missingTools}: ${capabilities.missing|join:, }"),
 			),
 
 			crud(app).ClassName("mt-2").Source("${files}").
 				Columns(
-					app.Column().Name("name").Label("${i18n.podFile.fileName}").Searchable(true),
-					app.Column().Name("size").Label("${i18n.podFile.fileSize}"),
-					app.Column().Name("time").Label("${i18n.podFile.modifyTime}"),
+					app.Column().Name("name").Label("${i18n.podFile.fileName}").Searchable(true).Sortable(true).
+						Tpl("${name}${linkTarget ? ' -> ' + linkTarget : ''}"),
+					app.Column().Name("type").Label("${i18n.podFile.fileType}").Sortable(true),
+					app.Column().Name("size").Label("${i18n.podFile.fileSize}").Tpl("${size|bytes}").Sortable(true),
+					app.Column().Name("time").Label("${i18n.podFile.modifyTime}").Type("datetime").Sortable(true),
+					app.Column().Name("perm").Label("${i18n.podFile.perm}").ClassName("font-mono"),
+					app.Column().Name("owner").Label("${i18n.podFile.owner}").Tpl("${owner || uid}:${group || gid}").Sortable(true),
 					app.Column().Type("operation").Buttons(
 						app.Button().
-							VisibleOn("${type!=='link'}").
+							VisibleOn("${type==='file'}").
+							Icon("fa fa-eye").
+							Label("${i18n.podFile.view}").
+							ActionType("drawer").
+							Drawer(viewer(app)),
+						app.Button().
+							VisibleOn("${type==='file' || (type==='link' && !linkDir)}").
+							Icon("fa fa-stream").
+							Label("${i18n.podFile.tail}").
+							ActionType("drawer").
+							Drawer(tail(app)),
+						app.Button().
+							VisibleOn("${writable && type==='file'}").
+							Icon("fa fa-edit").
+							Label("${i18n.podFile.edit}").
+							ActionType("drawer").
+							Reload("files").
+							Drawer(editor(app)),
+						app.Button().
+							VisibleOn("${type==='dir' || type==='file'}").
 							Icon("fa fa-download").
 							Label("${i18n.podFile.download}").
 							ActionType("download").
-							Api("post:"+api.Download+"?file=${name}&type=${type}"),
+							Api("get:"+containerApi(api.V2Archive, "dir + name")),
 						app.Button().
-							VisibleOn("${type==='dir'}").
+							VisibleOn("${type==='dir' || linkDir}").
 							Icon("fa fa-folder-open").
 							Label("${i18n.podFile.open}").
+							ActionType("link").
+							Link(filesLink("dir + name", "showHidden")),
+						app.Button().
+							VisibleOn("${writable}").
+							Icon("fa fa-pencil").
+							Label("${i18n.podFile.rename}").
+							ActionType("dialog").
+							Reload("files").
+							Dialog(
+								app.Dialog().Title("${i18n.podFile.rename} ${name}").Body(
+									app.Form().Api("post:"+containerApi(api.V2Move, "dir + name")).Body(
+										app.InputText().Name("to").Value("${name}").Required(true).
+											Label("${i18n.podFile.renameTo}").Description("${i18n.podFile.moveHint}"),
+									),
+								),
+							),
+						app.Button().
+							VisibleOn("${writable}").
+							Icon("fa fa-lock").
+							Label("${i18n.podFile.chmod}").
+							ActionType("dialog").
+							Reload("files").
+							Dialog(
+								app.Dialog().Title("${i18n.podFile.chmod} ${name}").Body(
+									app.Form().Api("post:"+containerApi(api.V2Chmod, "dir + name")).Body(
+										app.InputText().Name("mode").Required(true).
+											Label("${i18n.podFile.mode}").Description("${i18n.podFile.modeHint} ${perm}"),
+										app.Checkbox().Name("recursive").Option("${i18n.podFile.recursive}").VisibleOn("${type==='dir'}"),
+									),
+								),
+							),
+						app.Button().
+							VisibleOn("${writable}").
+							Icon("fa fa-user").
+							Label("${i18n.podFile.chown}").
+							ActionType("dialog").
+							Reload("files").
+							Dialog(
+								app.Dialog().Title("${i18n.podFile.chown} ${name}").Body(
+									app.Form().Api("post:"+containerApi(api.V2Chown, "dir + name")).Body(
+										app.InputText().Name("owner").Value("${owner || uid}").Label("${i18n.podFile.owner}"),
+										app.InputText().Name("group").Value("${group || gid}").Label("${i18n.podFile.group}").
+											Description("${i18n.podFile.chownHint}"),
+										app.Checkbox().Name("recursive").Option("${i18n.podFile.recursive}").VisibleOn("${type==='dir'}"),
+									),
+								),
+							),
+						app.Button().
+							VisibleOn("${writable}").
+							Icon("fa fa-trash").
+							Label("${i18n.podFile.remove}").
+							Level("danger").
 							ActionType("ajax").
-							Api("post:"+api.Files+"?dir=${name}").
+							ConfirmText("${i18n.podFile.confirmRemove} ${dir}${name}").
+							Api("delete:"+containerApi(api.V2Files, "dir + name")+"&recursive=${type==='dir'}").
 							Reload("files"),
 					),
 				),
 		),
 	)
 }
+
+// viewChunk is how many bytes of a file the viewer loads first, and more each time.
+const viewChunk = 64 << 10
+
+// viewer shows the file of the row, loading more of it on demand.
+func viewer(app *amisgo.App) comp.Drawer {
+	return app.Drawer().Title("${dir}${name}").Size("lg").Actions().Body(
+		app.Service().Name("viewer").
+			Api(containerApi(api.V2View, "dir + name")+fmt.Sprintf("&limit=${limit || %d}&raw=${raw}", viewChunk)).
+			Body(
+				app.Flex().Justify("flex-end").ClassName("mb-2").Items(
+					app.Button().Label("${i18n.podFile.raw}").VisibleOn("${pretty}").
+						ActionType("reload").Target("viewer?raw=true"),
+					app.Button().Label("${i18n.podFile.pretty}").VisibleOn("${raw && !more && (language==='json' || language==='yaml')}").
+						ActionType("reload").Target("viewer?raw=false"),
+				),
+				app.Alert().Level("info").ShowIcon(true).VisibleOn("${binary}").Body("${i18n.podFile.binary}"),
+				app.Code().Name("content").Language("${language}"),
+				app.Flex().Justify("center").ClassName("mt-2").Items(
+					app.Button().Label("${i18n.podFile.loadMore}").VisibleOn("${more && limit < max}").
+						ActionType("reload").Target(fmt.Sprintf("viewer?limit=${limit + %d}", viewChunk)),
+					app.Tpl().Tpl("${i18n.podFile.viewTruncated} ${max|bytes}").VisibleOn("${more && limit >= max}"),
+				),
+			),
+	)
+}
+
+// editor edits the text file of the row, the save fails if the file changed since it was opened.
+func editor(app *amisgo.App) comp.Drawer {
+	return app.Drawer().Title("${i18n.podFile.edit} ${dir}${name}").Size("lg").Body(
+		// the form submits the version loaded with the content
+		app.Form().
+			InitApi(containerApi(api.V2View, "dir + name")+"&raw=true").
+			Api("put:"+containerApi(api.V2Files, "dir + name")).
+			Body(
+				app.Alert().Level("warning").ShowIcon(true).VisibleOn("${!version}").Body("${i18n.podFile.notEditable} ${max|bytes}"),
+				app.Editor().Name("content").Language("${language}").Size("xxl").DisabledOn("${!version}"),
+			),
+	)
+}
+
+// tail follows the file of the row, the lines containing the filter if any.
+// Closing the drawer ends the stream, and the exec in the container.
+func tail(app *amisgo.App) comp.Drawer {
+	return app.Drawer().Title("${i18n.podFile.tail} ${dir}${name}").Size("lg").Actions().Body(
+		app.Form().WrapWithPanel(false).Body(
+			app.InputText().Name("filter").Clearable(true).Placeholder("${i18n.podFile.tailFilter}"),
+			app.Log().
+				Source(containerApi(api.V2Tail, "dir + name")+"&filter=${filter}").
+				Height(500).
+				AutoScroll(true).
+				Operation([]string{"stop", "restart", "clear", "showLineNumber"}),
+		),
+	)
+}
+
+// containerApi returns the v2 url of the container of the page made by url, at the path of the amis expression p.
+func containerApi(url func(namespace, pod, container, p string) string, p string) string {
+	return url("${namespace}", "${pod}", "${container}", "${"+p+"}") + "?cluster=${cluster}"
+}
+
+// filesLink links to the files page of the container of the page,
+// at the path of the amis expression p and showing the dotfiles as the expression hidden.
+func filesLink(p, hidden string) string {
+	return auth.FilesPage + "?" + urlQuery("cluster", "cluster", "namespace", "namespace", "pod", "pod", "container", "container",
+		"path", p, "hidden", hidden)
+}
@@ -2,60 +2,78 @@ package ui
 
 import (
 	"github.com/zrcoder/podFiles/internal/api"
+	"github.com/zrcoder/podFiles/internal/auth"
 
 	"github.com/zrcoder/amisgo"
 	"github.com/zrcoder/amisgo/comp"
 )
 
-const (
-	FilesPage = "/files"
-)
-
+// Index picks a container, the selection is kept in the query of the page so that every tab has its own,
+// along with the hidden parameter of the files pages.
 func Index(app *amisgo.App) comp.Page {
-	return page(app, app.HBox().Columns(nsList(app), podList(app), containerList(app)))
+	return page(app, app.HBox().Columns(clusterList(app), nsList(app), podList(app), containerList(app)))
+}
+
+func clusterList(app *amisgo.App) comp.Crud {
+	return crud(app).Name("clusters").Api(api.V2Clusters).
+		Columns(
+			app.Column().Name("cluster").Searchable(true).Label("${i18n.k8s.clusters}"),
+		).
+		OnEvent(
+			app.Event().RowClick(
+				app.EventActions(
+					app.EventAction().ActionType("link").Args(app.EventActionArgs().Link(
+						"/?" + urlQuery("cluster", "event.data.item.cluster", "hidden", "hidden"),
+					)),
+				),
+			),
+		)
 }
 
 func nsList(app *amisgo.App) comp.Crud {
-	return crud(app).Name("ns").Api(api.Namespaces).
+	return crud(app).Name("ns").Api(api.V2Namespaces + "?cluster=${cluster}").
 		Columns(
 			app.Column().Name("namespace").Searchable(true).Label("${i18n.k8s.namespaces}"),
 		).
 		OnEvent(
 			app.Event().RowClick(
 				app.EventActions(
-					app.EventAction().ActionType("ajax").Api("post:"+api.Namespaces+"?namespace=${event.data.item.namespace}"),
-					app.EventAction().ActionType("reload").ComponentName("pods"),
-					app.EventAction().ActionType("reload").ComponentName("containers"),
+					app.EventAction().ActionType("link").Args(app.EventActionArgs().Link(
+						"/?" + urlQuery("cluster", "cluster", "namespace", "event.data.item.namespace", "hidden", "hidden"),
+					)),
 				),
 			),
 		)
 }
 
 func podList(app *amisgo.App) comp.Crud {
-	return crud(app).Name("pods").Api(api.Pods).
+	return crud(app).Name("pods").Api(lazyApi(api.V2Pods("${namespace}")+"?cluster=${cluster}", "${namespace}")).
 		Columns(
 			app.Column().Name("pod").Searchable(true).Label("${i18n.k8s.runningPods}"),
 		).
 		OnEvent(
 			app.Event().RowClick(
 				app.EventActions(
-					app.EventAction().ActionType("ajax").Api("post:"+api.Pods+"?pod=${event.data.item.pod}"),
-					app.EventAction().ActionType("reload").ComponentName("containers"),
+					app.EventAction().ActionType("link").Args(app.EventActionArgs().Link(
+						"/?" + urlQuery("cluster", "cluster", "namespace", "namespace", "pod", "event.data.item.pod", "hidden", "hidden"),
+					)),
 				),
 			),
 		)
 }
 
 func containerList(app *amisgo.App) comp.Crud {
-	return crud(app).Name("containers").Api(api.Containers).
+	return crud(app).Name("containers").Api(lazyApi(api.V2Containers("${namespace}", "${pod}")+"?cluster=${cluster}", "${pod}")).
 		Columns(
 			app.Column().Name("container").Searchable(true).Label("${i18n.k8s.containers}"),
 		).
 		OnEvent(
 			app.Event().RowClick(
 				app.EventActions(
-					app.EventAction().ActionType("ajax").Api("post:"+api.Containers+"?container=${event.data.item.container}"),
-					app.EventAction().ActionType("link").Args(app.EventActionArgs().Link(FilesPage)),
+					app.EventAction().ActionType("link").Args(app.EventActionArgs().Link(
+						auth.FilesPage + "?" + urlQuery("cluster", "cluster", "namespace", "namespace", "pod", "pod",
+							"container", "event.data.item.container", "path", "'/'", "hidden", "hidden"),
+					)),
 				),
 			),
 		)
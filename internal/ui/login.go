@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"github.com/zrcoder/podFiles/conf"
+	"github.com/zrcoder/podFiles/internal/api"
+	"github.com/zrcoder/podFiles/internal/auth"
+
+	"github.com/zrcoder/amisgo"
+	"github.com/zrcoder/amisgo/comp"
+)
+
+// nextPage is where a login goes, back to the page which required it, see auth.SafeNext.
+const nextPage = "${" + auth.NextParam + " || '/'}"
+
+// Login is the login page, with the enabled ways to log in.
+func Login(app *amisgo.App) comp.Page {
+	body := []any{
+		// set by the failed single sign-on callbacks
+		app.Alert().Level("danger").ShowIcon(true).VisibleOn("${error}").Body(app.Tpl().Tpl("${error}")),
+	}
+	if conf.AuthEnabled(conf.AuthLocal) {
+		body = append(body, localLogin(app))
+	}
+	if conf.AuthEnabled(conf.AuthToken) {
+		body = append(body, app.Form().Title("${i18n.user.tokenLogin}").Api("post:"+api.TokenLogin).Redirect(nextPage).
+			SubmitText("${i18n.user.login}").
+			Body(app.InputPassword().Name("token").Label("${i18n.user.token}").Required(true)))
+	}
+	if conf.AuthEnabled(conf.AuthOIDC) {
+		body = append(body, app.Button().Icon("fa fa-id-badge").Label("${i18n.user.sso}").Level("primary").Block(true).
+			ActionType("url").Url(api.OIDCLogin+"?"+urlQuery(auth.NextParam, auth.NextParam)).Blank(false))
+	}
+	return app.Page().
+		BodyClassName("bg-light").
+		Title("${i18n.name}").
+		Toolbar(app.LocaleButtonGroupSelect()).
+		Body(app.Wrapper().ClassName("mx-auto mt-10").Style(map[string]any{"maxWidth": 400}).Body(body...))
+}
+
+// localLogin logs in with a local account, with a sign up form when anyone may register.
+func localLogin(app *amisgo.App) any {
+	login := app.Form().Title("${i18n.user.login}").Api("post:" + api.Login).Redirect(nextPage).
+		SubmitText("${i18n.user.login}").
+		Body(credentialInputs(app)...)
+	if !conf.SignUpEnabled() {
+		return login
+	}
+	return app.Tabs().Tabs(
+		app.Tab().Title("${i18n.user.login}").Tab(login),
+		app.Tab().Title("${i18n.user.signUp}").Tab(
+			app.Form().Title("${i18n.user.signUp}").Api("post:"+api.Register).
+				SubmitText("${i18n.user.signUp}").
+				Body(credentialInputs(app)...),
+		),
+	)
+}
+
+func credentialInputs(app *amisgo.App) []any {
+	return []any{
+		app.InputText().Name("name").Label("${i18n.user.name}").Required(true),
+		app.InputPassword().Name("password").Label("${i18n.user.password}").Required(true),
+	}
+}
@@ -1,6 +1,11 @@
 package ui
 
 import (
+	"strings"
+
+	"github.com/zrcoder/podFiles/internal/api"
+	"github.com/zrcoder/podFiles/internal/auth"
+
 	"github.com/zrcoder/amisgo"
 	"github.com/zrcoder/amisgo/comp"
 	"github.com/zrcoder/amisgo/schema"
@@ -10,10 +15,21 @@ func page(app *amisgo.App, body ...any) comp.Page {
 	return app.Page().
 		BodyClassName("bg-light").
 		Title("${i18n.name}").
-		Toolbar(app.LocaleButtonGroupSelect()).
+		Toolbar(userMenu(app), app.LocaleButtonGroupSelect()).
 		Body(body)
 }
 
+// userMenu shows the user of the session and the logout button, anonymous sessions have none.
+func userMenu(app *amisgo.App) comp.Service {
+	return app.Service().Api(api.User).ClassName("inline-block mr-2").Body(
+		app.Flex().AlignItems("center").VisibleOn("${user}").Items(
+			app.Tpl().Tpl("<i class='fa fa-user'></i> ${user}").ClassName("mr-2"),
+			app.Button().Icon("fa fa-sign-out").Label("${i18n.user.logout}").
+				ActionType("ajax").Api("post:"+api.Logout).Redirect(auth.LoginPage),
+		),
+	)
+}
+
 func crud(app *amisgo.App) comp.Crud {
 	return app.Crud().
 		SyncLocation(false).
@@ -25,3 +41,21 @@ func crud(app *amisgo.App) comp.Crud {
 			"pagination",
 		)
 }
+
+// urlQuery returns a url query of the names and the amis expressions of their values in pairs,
+// url encoding the values.
+func urlQuery(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(pairs[i] + "=${" + pairs[i+1] + "|url_encode}")
+	}
+	return sb.String()
+}
+
+// lazyApi returns a get api of url, which is only called once the expression sendOn is truthy.
+func lazyApi(url, sendOn string) schema.Schema {
+	return schema.Schema{"method": "get", "url": url, "sendOn": sendOn}
+}
@@ -0,0 +1,307 @@
+// Package user keeps the local podFiles accounts, with bcrypt hashed passwords,
+// in memory and optionally in a JSON file, which the replicas may share.
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zrcoder/podFiles/internal/models"
+	"github.com/zrcoder/podFiles/internal/util/log"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLen = 8
+
+var (
+	ErrInvalidName     = errors.New("user names have 1 to 64 letters, digits, '.', '_', '@' or '-'")
+	ErrInvalidPassword = fmt.Errorf("passwords have at least %d characters", minPasswordLen)
+	ErrExists          = errors.New("user already exists")
+	ErrNotFound        = errors.New("user not found")
+	// ErrCredentials is returned for unknown users and wrong passwords alike
+	ErrCredentials = errors.New("invalid user name or password")
+)
+
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// dummyHash is compared for unknown users, so that they take as long as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("podfiles-dummy-password"), bcrypt.DefaultCost)
+
+type User struct {
+	Name         string   `json:"name"`
+	PasswordHash []byte   `json:"passwordHash"`
+	Admin        bool     `json:"admin,omitempty"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
+// Identity returns the identity of u for the sessions.
+func (u *User) Identity() *models.Identity {
+	return &models.Identity{User: u.Name, Groups: slices.Clone(u.Groups), Admin: u.Admin, Local: true}
+}
+
+// Store keeps the users, it is safe for concurrent use.
+// The file is read again whenever another replica changed it.
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	users map[string]*User
+	// removed are the names of the removed users, so that a removal is told apart
+	// from a user registered by another replica
+	removed map[string]bool
+	// modTime and size are the ones of the file when last read or written
+	modTime time.Time
+	size    int64
+}
+
+type storeFile struct {
+	Users   []*User  `json:"users"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// New returns the store persisted in the JSON file at path, or in memory only if path is empty.
+func New(path string) (*Store, error) {
+	s := &Store{path: path, users: map[string]*User{}, removed: map[string]bool{}}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reads the file again if it changed since, it is called with s.mu held.
+func (s *Store) reload() error {
+	if s.path == "" {
+		return nil
+	}
+	fi, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(s.modTime) && fi.Size() == s.size {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.users = make(map[string]*User, len(f.Users))
+	for _, u := range f.Users {
+		s.users[u.Name] = u
+	}
+	s.removed = make(map[string]bool, len(f.Removed))
+	for _, name := range f.Removed {
+		s.removed[name] = true
+	}
+	s.modTime, s.size = fi.ModTime(), fi.Size()
+	return nil
+}
+
+// refresh is reload for the lookups, which go on with the users known so far when the file can't be read.
+func (s *Store) refresh() {
+	if err := s.reload(); err != nil {
+		slog.Warn("reload users", log.Error(err))
+	}
+}
+
+// Bootstrap makes sure the admin user exists. Without a password, one is generated
+// and returned when the store has no users yet. The replicas sharing the file race to
+// create it then, only the one that does returns the password, the others load its admin.
+func (s *Store) Bootstrap(name, password string) (generated string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return "", err
+	}
+	if u, ok := s.users[name]; ok {
+		if !u.Admin {
+			return "", fmt.Errorf("user %s exists but is not an admin", name)
+		}
+		return "", nil
+	}
+	if password == "" {
+		if len(s.users) > 0 {
+			return "", nil
+		}
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+		generated = password
+	}
+	err = s.add(name, password, true, generated != "")
+	if generated != "" && errors.Is(err, fs.ErrExist) {
+		if err := s.reload(); err != nil {
+			return "", err
+		}
+		if u, ok := s.users[name]; ok && u.Admin {
+			return "", nil
+		}
+		return "", fmt.Errorf("%s has no user %s, set its password", s.path, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return generated, nil
+}
+
+// Register adds a user.
+func (s *Store) Register(name, password string, admin bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	return s.add(name, password, admin, false)
+}
+
+// add adds a user, with exclusive the file must not exist yet.
+func (s *Store) add(name, password string, admin, exclusive bool) error {
+	if !nameRegexp.MatchString(name) {
+		return ErrInvalidName
+	}
+	if len(password) < minPasswordLen {
+		return ErrInvalidPassword
+	}
+	if _, ok := s.users[name]; ok {
+		return ErrExists
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	s.users[name] = &User{Name: name, PasswordHash: hash, Admin: admin}
+	removed := s.removed[name]
+	delete(s.removed, name)
+	if err := s.save(exclusive); err != nil {
+		delete(s.users, name)
+		s.removed[name] = removed
+		return err
+	}
+	return nil
+}
+
+// Authenticate returns the user with name if password matches.
+func (s *Store) Authenticate(name, password string) (*User, error) {
+	s.mu.Lock()
+	s.refresh()
+	u, ok := s.users[name]
+	s.mu.Unlock()
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)); err != nil {
+		return nil, ErrCredentials
+	}
+	return u, nil
+}
+
+// Get returns the user with name.
+func (s *Store) Get(name string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	u, ok := s.users[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+// Removed reports whether the user with name was removed and not registered again.
+// Unlike Get, it is false for the users this replica doesn't know,
+// such as the ones another replica registered.
+func (s *Store) Removed(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	return s.removed[name]
+}
+
+// Remove removes the user with name.
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	u, ok := s.users[name]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.users, name)
+	s.removed[name] = true
+	if err := s.save(false); err != nil {
+		s.users[name] = u
+		delete(s.removed, name)
+		return err
+	}
+	return nil
+}
+
+// save writes the users to the file, through a temporary file so that it is never partial.
+// With exclusive, it fails with fs.ErrExist if the file exists.
+func (s *Store) save(exclusive bool) error {
+	if s.path == "" {
+		return nil
+	}
+	f := storeFile{Users: make([]*User, 0, len(s.users))}
+	for _, u := range s.users {
+		f.Users = append(f.Users, u)
+	}
+	slices.SortFunc(f.Users, func(a, b *User) int { return strings.Compare(a.Name, b.Name) })
+	for name := range s.removed {
+		f.Removed = append(f.Removed, name)
+	}
+	slices.Sort(f.Removed)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if exclusive {
+		// unlike a rename, a link doesn't replace the file
+		err = os.Link(tmp.Name(), s.path)
+	} else {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		return err
+	}
+	// the replicas read it again, this one knows it already
+	if fi, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = fi.ModTime(), fi.Size()
+	}
+	return nil
+}
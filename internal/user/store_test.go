@@ -0,0 +1,191 @@
+package user
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := s.Bootstrap("admin", "")
+	if err != nil || generated == "" {
+		t.Fatalf("Bootstrap() = %q, %v, want a generated password", generated, err)
+	}
+	if err := s.Register("alice", "alice-password", false); err != nil {
+		t.Fatal(err)
+	}
+
+	registerTests := []struct {
+		name, password string
+		want           error
+	}{
+		{"alice", "another-password", ErrExists},
+		{"bob", "short", ErrInvalidPassword},
+		{"../bob", "bob-password", ErrInvalidName},
+		{"", "bob-password", ErrInvalidName},
+	}
+	for _, tt := range registerTests {
+		if err := s.Register(tt.name, tt.password, false); !errors.Is(err, tt.want) {
+			t.Errorf("Register(%q, %q) = %v, want %v", tt.name, tt.password, err, tt.want)
+		}
+	}
+
+	// reload from the file
+	s, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authTests := []struct {
+		name, password string
+		admin          bool
+		want           error
+	}{
+		{"admin", generated, true, nil},
+		{"alice", "alice-password", false, nil},
+		{"alice", "wrong-password", false, ErrCredentials},
+		{"nobody", "alice-password", false, ErrCredentials},
+	}
+	for _, tt := range authTests {
+		u, err := s.Authenticate(tt.name, tt.password)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Authenticate(%q, %q) = %v, want %v", tt.name, tt.password, err, tt.want)
+			continue
+		}
+		if err == nil && u.Identity().Admin != tt.admin {
+			t.Errorf("Authenticate(%q) admin = %v, want %v", tt.name, u.Admin, tt.admin)
+		}
+	}
+
+	// an existing admin is kept, the password is not reset
+	if generated, err := s.Bootstrap("admin", "new-admin-password"); err != nil || generated != "" {
+		t.Errorf("Bootstrap() again = %q, %v", generated, err)
+	}
+	if _, err := s.Bootstrap("alice", ""); err == nil {
+		t.Error("Bootstrap() with a user that is not an admin succeeded")
+	}
+
+	if err := s.Remove("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a removed user = %v, want %v", err, ErrNotFound)
+	}
+
+	if !s.Removed("alice") || s.Removed("nobody") {
+		t.Errorf("Removed() = %v for alice, %v for nobody, want true, false", s.Removed("alice"), s.Removed("nobody"))
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("users file mode = %o, want 600", perm)
+	}
+}
+
+func TestStoreReplicas(t *testing.T) {
+	// the replicas share the file
+	path := filepath.Join(t.TempDir(), "users.json")
+	a, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Register("alice", "alice-password", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Authenticate("alice", "alice-password"); err != nil {
+		t.Errorf("Authenticate() of a user registered by another replica = %v", err)
+	}
+	if err := b.Register("bob", "bob-password", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get("bob"); err != nil {
+		t.Errorf("Get() of a user registered by another replica = %v", err)
+	}
+
+	if err := b.Remove("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Removed("alice") {
+		t.Error("a user removed by another replica is not Removed()")
+	}
+	if _, err := a.Get("bob"); err != nil {
+		t.Errorf("Get() of a user kept by another replica = %v", err)
+	}
+	if err := a.Register("alice", "new-alice-password", false); err != nil {
+		t.Fatal(err)
+	}
+	if b.Removed("alice") {
+		t.Error("a user registered again is Removed()")
+	}
+}
+
+func TestBootstrapReplicas(t *testing.T) {
+	// the replicas start together on the same new file, one generates the password
+	path := filepath.Join(t.TempDir(), "users.json")
+	stores := make([]*Store, 4)
+	for i := range stores {
+		s, err := New(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores[i] = s
+	}
+	generated := make([]string, len(stores))
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if generated[i], err = s.Bootstrap("admin", ""); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var password string
+	for _, g := range generated {
+		if g != "" && password != "" {
+			t.Fatal("several replicas generated a password")
+		}
+		if g != "" {
+			password = g
+		}
+	}
+	if password == "" {
+		t.Fatal("no replica generated a password")
+	}
+	for i, s := range stores {
+		if _, err := s.Authenticate("admin", password); err != nil {
+			t.Errorf("replica %d: Authenticate() with the generated password = %v", i, err)
+		}
+	}
+
+	// a file without users is not bootstrapped again without a password
+	empty := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(empty, []byte(`{"users":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Bootstrap("admin", ""); err == nil {
+		t.Error("Bootstrap() of a file without users succeeded without a password")
+	}
+}
@@ -0,0 +1,90 @@
+// Package fspath normalizes and validates paths inside containers.
+// Every path taken from a request goes through it before reaching an exec.
+package fspath
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// maxPathSize is PATH_MAX on linux
+const maxPathSize = 4096
+
+var (
+	ErrEmpty   = errors.New("path is empty")
+	ErrNul     = errors.New("path contains a NUL byte")
+	ErrEscape  = errors.New("path escapes its directory")
+	ErrNotAbs  = errors.New("path is not absolute")
+	ErrSlash   = errors.New("name contains a slash")
+	ErrTooLong = errors.New("path is too long")
+)
+
+// Name validates a single path element, such as a directory entry or an uploaded file name.
+func Name(name string) (string, error) {
+	if err := check(name); err != nil {
+		return "", err
+	}
+	if strings.Contains(name, "/") {
+		return "", ErrSlash
+	}
+	if name == "." || name == ".." {
+		return "", ErrEscape
+	}
+	return name, nil
+}
+
+// Rel normalizes a path relative to a directory, it must stay inside that directory.
+// Leading and trailing slashes are ignored.
+func Rel(p string) (string, error) {
+	if err := check(p); err != nil {
+		return "", err
+	}
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", ErrEmpty
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return "", ErrEscape
+		}
+	}
+	return path.Clean(p), nil
+}
+
+// Abs normalizes an absolute path.
+func Abs(p string) (string, error) {
+	if err := check(p); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(p, "/") {
+		return "", ErrNotAbs
+	}
+	return path.Clean(p), nil
+}
+
+// Join joins dir with the relative path p, failing if the result escapes dir.
+func Join(dir, p string) (string, error) {
+	dir, err := Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	p, err = Rel(p)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, p), nil
+}
+
+func check(p string) error {
+	if p == "" {
+		return ErrEmpty
+	}
+	if strings.IndexByte(p, 0) >= 0 {
+		return ErrNul
+	}
+	if len(p) > maxPathSize {
+		return ErrTooLong
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package fspath
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "plain", input: "app.log", want: "app.log"},
+		{name: "shell", input: "a; rm -rf ~", want: "a; rm -rf ~"},
+		{name: "quotes", input: `it's "x"`, want: `it's "x"`},
+		{name: "dash", input: "-rf", want: "-rf"},
+		{name: "hidden", input: ".env", want: ".env"},
+		{name: "empty", input: "", wantErr: ErrEmpty},
+		{name: "dot", input: ".", wantErr: ErrEscape},
+		{name: "dotdot", input: "..", wantErr: ErrEscape},
+		{name: "slash", input: "a/b", wantErr: ErrSlash},
+		{name: "nul", input: "a\x00b", wantErr: ErrNul},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Name(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Name() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Name() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRel(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "plain", input: "a", want: "a"},
+		{name: "nested", input: "a/b/", want: "a/b"},
+		{name: "leading slash", input: "/a", want: "a"},
+		{name: "dot", input: "./a//b", want: "a/b"},
+		{name: "dotdot", input: "../etc/passwd", wantErr: ErrEscape},
+		{name: "inner dotdot", input: "a/../../b", wantErr: ErrEscape},
+		{name: "dotdot prefix is a name", input: "..a", want: "..a"},
+		{name: "root", input: "/", wantErr: ErrEmpty},
+		{name: "nul", input: "a\x00", wantErr: ErrNul},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Rel(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rel() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Rel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		p       string
+		want    string
+		wantErr error
+	}{
+		{name: "root", dir: "/", p: "etc", want: "/etc"},
+		{name: "nested", dir: "/var/log/", p: "app/x.log", want: "/var/log/app/x.log"},
+		{name: "escape", dir: "/var/log", p: "../../etc/shadow", wantErr: ErrEscape},
+		{name: "relative dir", dir: "var", p: "log", wantErr: ErrNotAbs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Join(tt.dir, tt.p)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Join() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Join() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}